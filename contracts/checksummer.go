@@ -0,0 +1,8 @@
+package contracts
+
+import "github.com/goodsru/go-universal-network-adapter/models"
+
+type Checksummer interface {
+	// Get checksum of remote file, computed by server, as lower case hex string
+	Checksum(remoteFile *models.RemoteFile, algorithm models.ChecksumAlgorithm) (string, error)
+}
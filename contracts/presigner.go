@@ -0,0 +1,10 @@
+package contracts
+
+import "github.com/goodsru/go-universal-network-adapter/models"
+
+type Presigner interface {
+	// Get url, which allows to download remote file without credentials until expiration
+	PresignGet(remoteFile *models.RemoteFile, options *models.PresignOptions) (string, error)
+	// Get url, which allows to upload remote file with PUT request without credentials until expiration
+	PresignPut(remoteFile *models.RemoteFile, options *models.PresignOptions) (string, error)
+}
@@ -0,0 +1,8 @@
+package contracts
+
+import "github.com/goodsru/go-universal-network-adapter/models"
+
+type RecursiveRemover interface {
+	// Remove file, or directory with all files and subdirectories. Returns removed paths in deletion order
+	RemoveRecursive(remoteFile *models.RemoteFile, options *models.RemoveOptions) ([]string, error)
+}
@@ -0,0 +1,12 @@
+package contracts
+
+import (
+	"io"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+type Uploader interface {
+	// Upload content to remote file. Size of content is required by some protocols, -1 if it is unknown
+	Upload(remoteFile *models.RemoteFile, content io.Reader, size int64) error
+}
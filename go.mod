@@ -16,4 +16,5 @@ require (
 	github.com/stretchr/objx v0.1.1 // indirect
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/crypto v0.31.0
+	golang.org/x/text v0.21.0
 )
@@ -0,0 +1,14 @@
+package models
+
+// Hash algorithm of remote file checksum
+type ChecksumAlgorithm string
+
+const (
+	ChecksumMD5    ChecksumAlgorithm = "md5"
+	ChecksumSHA1   ChecksumAlgorithm = "sha1"
+	ChecksumSHA256 ChecksumAlgorithm = "sha256"
+	ChecksumSHA512 ChecksumAlgorithm = "sha512"
+	ChecksumCRC32  ChecksumAlgorithm = "crc32"
+	// Castagnoli CRC32 (s3)
+	ChecksumCRC32C ChecksumAlgorithm = "crc32c"
+)
@@ -29,4 +29,12 @@ type Credentials struct {
 	RsaPrivateKeyPassphrase string
 	TLSConfig               *tls.Config
 	TLSMode                 TLSMode
+	// declarative TLS settings applied on top of TLSConfig (https, ftps, s3)
+	TLSOptions *TLSOptions
+	// http authentication scheme. Basic auth is used, if not set
+	HttpAuth *HttpAuth
+	// s3 server-side encryption settings and SSE-C customer key
+	S3Encryption *S3Encryption
+	// s3 credentials chain: environment, shared files, web identity and assumed role
+	S3Credentials *S3Credentials
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"crypto/tls"
+
 	"golang.org/x/crypto/ssh"
 	goUrl "net/url"
 	"time"
@@ -18,6 +20,14 @@ type Destination struct {
 	Credentials *Credentials
 	// connection timeout
 	Timeout time.Duration
+	// http request customization (method, headers, body, cookies). Used by http/https only
+	HttpRequest *HttpRequestOptions
+	// region, endpoint, addressing style and signature settings. Used by s3 only
+	S3 *S3Options
+	// data connection mode and NAT settings. Used by ftp only
+	Ftp *FtpOptions
+	// symlink handling of browse. Used by sftp only
+	Sftp *SftpOptions
 }
 
 // Constructor for Destination
@@ -44,6 +54,14 @@ type ParsedDestination struct {
 	ParsedUrl *goUrl.URL
 	// connection timeout. Defaults to 30 seconds, if NewDestination is used
 	Timeout time.Duration
+	// http request customization
+	HttpRequest *HttpRequestOptions
+	// s3 connection settings
+	S3 *S3Options
+	// ftp data connection settings
+	Ftp *FtpOptions
+	// sftp browse settings
+	Sftp *SftpOptions
 }
 
 // returns URL hostname
@@ -79,6 +97,15 @@ func (pd *ParsedDestination) GetRsaPrivateKey() (ssh.Signer, error) {
 	return ssh.ParsePrivateKeyWithPassphrase([]byte(pd.Credentials.RsaPrivateKey), []byte(pd.Credentials.RsaPrivateKeyPassphrase))
 }
 
+// returns TLS config built from Credentials.TLSConfig and Credentials.TLSOptions.
+// Returns nil, if none of them is set
+func (pd *ParsedDestination) GetTLSConfig() (*tls.Config, error) {
+	if pd.Credentials.TLSOptions == nil {
+		return pd.Credentials.TLSConfig, nil
+	}
+	return pd.Credentials.TLSOptions.BuildTLSConfig(pd.Credentials.TLSConfig)
+}
+
 // parses Destination to ParsedDestination
 func ParseDestination(destination *Destination) (*ParsedDestination, error) {
 	parsedUrl, err := goUrl.Parse(destination.Url)
@@ -97,5 +124,6 @@ func ParseDestination(destination *Destination) (*ParsedDestination, error) {
 
 	parsedUrl.User = nil
 
-	return &ParsedDestination{Url: parsedUrl.String(), Protocol: destination.Protocol, Credentials: *credentials, ParsedUrl: parsedUrl, Timeout: destination.Timeout}, nil
+	return &ParsedDestination{Url: parsedUrl.String(), Protocol: destination.Protocol, Credentials: *credentials, ParsedUrl: parsedUrl, Timeout: destination.Timeout,
+		HttpRequest: destination.HttpRequest, S3: destination.S3, Ftp: destination.Ftp, Sftp: destination.Sftp}, nil
 }
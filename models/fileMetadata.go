@@ -0,0 +1,58 @@
+package models
+
+import (
+	"os"
+	"time"
+)
+
+// POSIX and protocol metadata of remote file. Fields, which server did not report, are nil or empty. Symbolic link
+// target, ETag and content type are kept in RemoteFile, s3 storage class in S3ObjectInfo
+type FileMetadata struct {
+	// type and permission bits (sftp, ftp MLSD unix.mode fact and unix LIST)
+	Mode *os.FileMode
+	// numeric owner and group (sftp, ftp MLSD unix.uid and unix.gid facts)
+	Uid *uint32
+	Gid *uint32
+	// owner and group names (ftp MLSD unix.owner and unix.group facts and unix LIST)
+	Owner string
+	Group string
+	// last access time (sftp)
+	Atime time.Time
+	// facts as server reported them: MLSD facts with lower case names, http response headers with canonical names,
+	// sftp extended attributes
+	Facts map[string]string
+}
+
+// Parses mode of ls -l, i.e. "drwxr-sr-t". String must have at least 10 characters
+func ParseModeString(modeString string) os.FileMode {
+	var mode os.FileMode
+	switch modeString[0] {
+	case 'd':
+		mode = os.ModeDir
+	case 'l':
+		mode = os.ModeSymlink
+	case 'b':
+		mode = os.ModeDevice
+	case 'c':
+		mode = os.ModeDevice | os.ModeCharDevice
+	case 'p':
+		mode = os.ModeNamedPipe
+	case 's':
+		mode = os.ModeSocket
+	}
+	for i, char := range modeString[1:10] {
+		// r, w and x bits of owner, group and others. s, S, t and T replace x
+		if char != '-' && char != 'S' && char != 'T' {
+			mode |= 1 << uint(8-i)
+		}
+		switch {
+		case i == 2 && (char == 's' || char == 'S'):
+			mode |= os.ModeSetuid
+		case i == 5 && (char == 's' || char == 'S'):
+			mode |= os.ModeSetgid
+		case i == 8 && (char == 't' || char == 'T'):
+			mode |= os.ModeSticky
+		}
+	}
+	return mode
+}
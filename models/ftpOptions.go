@@ -0,0 +1,73 @@
+package models
+
+// FTP data connection mode
+type FtpDataConnectionMode string
+
+const (
+	// passive mode with EPSV, falling back to PASV, if server does not support EPSV
+	FtpPassiveMode FtpDataConnectionMode = "passive"
+	// passive mode with EPSV only
+	FtpEPSVMode FtpDataConnectionMode = "epsv"
+	// passive mode with PASV only, for legacy servers, which do not understand EPSV
+	FtpPASVMode FtpDataConnectionMode = "pasv"
+	// active mode: client listens for data connections and sends its address with PORT/EPRT
+	FtpActiveMode FtpDataConnectionMode = "active"
+)
+
+// FTP transfer type of downloads
+type FtpTransferType string
+
+const (
+	// TYPE I: file is downloaded as is
+	FtpBinaryType FtpTransferType = "I"
+	// TYPE A: text file, which line endings are converted by server to CRLF, and then by client to LF
+	FtpASCIIType FtpTransferType = "A"
+)
+
+// Encoding of file names on FTP control connection and in listings
+type FtpEncoding string
+
+const (
+	FtpUTF8   FtpEncoding = "utf-8"
+	FtpCP1251 FtpEncoding = "cp1251"
+	FtpKOI8R  FtpEncoding = "koi8-r"
+	FtpLatin1 FtpEncoding = "latin-1"
+)
+
+// Protection level of FTPS data connections, sent with PROT command
+type FtpDataProtection string
+
+const (
+	// PROT P: data connections are protected with TLS
+	FtpPrivateProtection FtpDataProtection = "P"
+	// PROT C: data connections are not protected, only control connection is
+	FtpClearProtection FtpDataProtection = "C"
+)
+
+// FTP data connection, transfer type, encoding and FTPS settings for legacy servers and servers behind NAT
+type FtpOptions struct {
+	// defaults to FtpPassiveMode
+	DataConnectionMode FtpDataConnectionMode
+	// address to listen for data connections in active mode, i.e. "0.0.0.0:20000". Host defaults to local
+	// address of control connection, port is random by default
+	ActiveListenAddr string
+	// connect to IP address of control connection instead of address advertised in PASV reply. For servers
+	// behind NAT, which advertise their private address
+	UseControlIPForPASV bool
+	// defaults to FtpBinaryType
+	TransferType FtpTransferType
+	// file names are encoded with it in commands and decoded from it in listings, so RemoteFile.Name is UTF-8.
+	// Defaults to FtpUTF8
+	Encoding FtpEncoding
+	// send OPTS UTF8 ON, which switches some servers (i.e. IIS) to UTF-8 file names. Server rejection is ignored,
+	// as many servers use UTF-8 without negotiation. Can not be used with other encodings
+	NegotiateUTF8 bool
+	// FTPS: defaults to FtpPrivateProtection
+	DataProtection FtpDataProtection
+	// FTPS: resume TLS session of control connection on data connections, which is required by vsftpd
+	// (require_ssl_reuse) and FileZilla Server. ClientSessionCache of TLSConfig is used, if set
+	TLSSessionReuse bool
+	// FTPS: send CCC after login, so that commands are sent in clear text, i.e. through NAT firewalls, which
+	// inspect control connection. Data connections are protected according to DataProtection
+	ClearControlChannel bool
+}
@@ -0,0 +1,62 @@
+package models
+
+// HttpAuthType selects HTTP authentication scheme
+type HttpAuthType string
+
+const (
+	// Basic auth using Credentials.User and Credentials.Password
+	HttpAuthBasic HttpAuthType = "basic"
+	// Authorization: Bearer <HttpAuth.Token>
+	HttpAuthBearer HttpAuthType = "bearer"
+	// Digest auth (RFC 7616) using Credentials.User and Credentials.Password
+	HttpAuthDigest HttpAuthType = "digest"
+	// OAuth2 client credentials grant. Token is cached until expiration and refreshed on demand
+	HttpAuthOAuth2 HttpAuthType = "oauth2"
+	// static api key passed in header or query param
+	HttpAuthApiKey HttpAuthType = "apikey"
+	// HMAC-SHA256 request signing
+	HttpAuthHmac HttpAuthType = "hmac"
+)
+
+// HTTP authentication settings. If not set, Basic auth is used when Credentials.User or
+// Credentials.Password is not empty
+type HttpAuth struct {
+	Type HttpAuthType
+	// bearer token
+	Token string
+	// OAuth2 client credentials settings
+	OAuth2 *OAuth2ClientCredentials
+	// api key settings
+	ApiKey *ApiKey
+	// HMAC signing settings
+	Hmac *HmacSigning
+}
+
+// OAuth2 client credentials grant settings
+type OAuth2ClientCredentials struct {
+	// token endpoint url
+	TokenUrl string
+	ClientId string
+	// client secret, sent to token endpoint using Basic auth
+	ClientSecret string
+	Scopes       []string
+}
+
+// Static api key
+type ApiKey struct {
+	// header or query param name
+	Name  string
+	Value string
+	// pass key as query param instead of header
+	InQuery bool
+}
+
+// HMAC-SHA256 request signing settings.
+// String to sign is METHOD + "\n" + request uri + "\n" + Date header + "\n" + hex sha256 of body.
+// Signature is sent as `HMAC-SHA256 keyId="<KeyId>",signature="<base64 signature>"`
+type HmacSigning struct {
+	KeyId  string
+	Secret string
+	// header to put signature to. Defaults to Authorization
+	Header string
+}
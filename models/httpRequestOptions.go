@@ -0,0 +1,19 @@
+package models
+
+import "net/http"
+
+// HTTP request customization for Destination
+type HttpRequestOptions struct {
+	// request method for Download. Defaults to GET. Stat uses HEAD for GET requests and this method
+	// otherwise, reading only response headers
+	Method string
+	// additional request headers
+	Headers http.Header
+	// request body. Sent with each request, so it can be repeated on auth challenge
+	Body []byte
+	// cookie jar to receive and send cookies, e.g. session cookies from a login call.
+	// May be shared between destinations
+	CookieJar http.CookieJar `json:"-"`
+	// User-Agent header value
+	UserAgent string
+}
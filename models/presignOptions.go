@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// expiry of presigned urls, if PresignOptions.Expires is not set
+const DefaultPresignExpires = 15 * time.Minute
+
+// max expiry of presigned urls
+const MaxPresignExpires = 7 * 24 * time.Hour
+
+// Presigned url settings
+type PresignOptions struct {
+	// url lifetime. Defaults to DefaultPresignExpires, can not exceed MaxPresignExpires
+	Expires time.Duration
+	// response header overrides of presigned GET
+	ResponseContentType        string
+	ResponseContentDisposition string
+	ResponseCacheControl       string
+	ResponseContentEncoding    string
+	ResponseContentLanguage    string
+	// content type of presigned PUT. Uploader must send the same Content-Type header
+	ContentType string
+}
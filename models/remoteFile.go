@@ -7,6 +7,9 @@ import (
 	"time"
 )
 
+// Size of remote file, that server did not report
+const UnknownSize int64 = -1
+
 // Remote file model
 type RemoteFile struct {
 	// filename
@@ -15,11 +18,22 @@ type RemoteFile struct {
 	Path string
 	// remote file parsed destination model
 	ParsedDestination *ParsedDestination `json:"-"`
-	// file size, bytes
+	// file size, bytes. UnknownSize if server did not report it
 	Size int64
 	// file modification date
 	Lastmod time.Time
 	IsDir   bool
+	// target of symbolic link, as it is stored in the link (sftp, scp). Empty for other files
+	LinkTarget string
+	// entity tag of remote file (http, s3)
+	ETag string
+	// media type of remote file (http, s3)
+	ContentType string
+	// s3 object metadata. Filled by s3 Stat
+	S3 *S3ObjectInfo
+	// mode, owner, access time and other metadata. Filled by sftp Stat and Browse, ftp MLSD/MLST and unix
+	// LIST, scp and http Stat
+	Metadata *FileMetadata
 }
 
 // Constructor for RemoteFile
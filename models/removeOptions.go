@@ -0,0 +1,9 @@
+package models
+
+// Options of recursive remove of directories and s3 prefixes
+type RemoveOptions struct {
+	// only list paths, which would be removed, without removing them
+	DryRun bool
+	// allow s3 RemovePrefix to remove all objects of bucket, when url has no key prefix
+	AllowBucketRoot bool
+}
@@ -0,0 +1,42 @@
+package models
+
+// Source of S3 access keys
+type S3CredentialsProvider string
+
+const (
+	// Credentials.User as access key id, Credentials.Password as secret key and S3Credentials.SessionToken
+	S3ProviderStatic S3CredentialsProvider = "static"
+	// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+	S3ProviderEnv S3CredentialsProvider = "env"
+	// profile of shared credentials file, then profile of shared config file
+	S3ProviderShared S3CredentialsProvider = "shared"
+	// temporary credentials of AssumeRoleWithWebIdentity with token from file
+	S3ProviderWebIdentity S3CredentialsProvider = "webidentity"
+)
+
+// S3 credentials chain settings. If not set, static Credentials.User and Credentials.Password are used
+type S3Credentials struct {
+	// providers tried in order, the first one with credentials is used.
+	// Defaults to static, env, shared and webidentity
+	Providers []S3CredentialsProvider
+	// session token of static temporary credentials
+	SessionToken string
+	// shared files profile. Defaults to AWS_PROFILE or "default"
+	Profile string
+	// defaults to AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials
+	SharedCredentialsFile string
+	// defaults to AWS_CONFIG_FILE or ~/.aws/config
+	SharedConfigFile string
+	// file with OIDC token. Defaults to AWS_WEB_IDENTITY_TOKEN_FILE
+	WebIdentityTokenFile string
+	// role assumed with web identity token. Defaults to AWS_ROLE_ARN
+	WebIdentityRoleArn string
+	// role assumed with credentials of the chain. Requests are signed with temporary credentials of the role
+	RoleArn string
+	// defaults to generated name
+	RoleSessionName string
+	// external id of the role trust policy
+	ExternalId string
+	// STS endpoint url. Defaults to AWS STS endpoint of S3Options.Region
+	StsEndpoint string
+}
@@ -0,0 +1,21 @@
+package models
+
+// S3 server-side encryption of written objects
+type S3ServerSideEncryption string
+
+const (
+	// SSE-S3, keys are managed by S3
+	S3EncryptionAES256 S3ServerSideEncryption = "AES256"
+	// SSE-KMS, keys are managed by AWS KMS
+	S3EncryptionKMS S3ServerSideEncryption = "aws:kms"
+)
+
+// S3 encryption settings. Applied to reads and writes of s3 objects
+type S3Encryption struct {
+	// encryption of objects written by adapter. Bucket default encryption is used, if empty
+	ServerSideEncryption S3ServerSideEncryption
+	// KMS key id or ARN for S3EncryptionKMS. Bucket default KMS key is used, if empty
+	KMSKeyId string
+	// SSE-C 256-bit customer key. Objects written with it can be read with the same key only. Requires https
+	CustomerKey []byte
+}
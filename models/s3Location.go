@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	goUrl "net/url"
+	"strings"
+)
+
+// Bucket and key of S3 object or prefix
+type S3Location struct {
+	// endpoint url with scheme, taken from http/https urls. Empty for s3:// urls
+	Endpoint string
+	// bucket is a subdomain of endpoint in http/https url: https://bucket.endpoint/key
+	VirtualHosted bool
+	// bucket name
+	Bucket string
+	// object key or prefix. Empty for bucket root
+	Key string
+	// object version from versionId query parameter. Empty for the latest version
+	VersionId string
+}
+
+// parses s3://bucket/key/with/slashes urls, and http(s) urls of addressing style: path-style
+// http(s)://endpoint/bucket/key/with/slashes or virtual-hosted http(s)://bucket.endpoint/key/with/slashes.
+// Specific object version is set with versionId query parameter: s3://bucket/key?versionId=id
+func ParseS3Url(parsedUrl *goUrl.URL, style S3AddressingStyle) (*S3Location, error) {
+	switch parsedUrl.Scheme {
+	case "s3":
+		if parsedUrl.Host == "" {
+			return nil, fmt.Errorf("s3 url has no bucket: %s", parsedUrl)
+		}
+		return &S3Location{Bucket: parsedUrl.Host, Key: strings.TrimPrefix(parsedUrl.Path, "/"),
+			VersionId: parsedUrl.Query().Get("versionId")}, nil
+	case "http", "https":
+		if style == S3VirtualHostedStyle {
+			labels := strings.SplitN(parsedUrl.Host, ".", 2)
+			if len(labels) != 2 || labels[0] == "" || labels[1] == "" || net.ParseIP(parsedUrl.Hostname()) != nil {
+				return nil, fmt.Errorf("virtual-hosted s3 url has no bucket in host: %s", parsedUrl)
+			}
+			return &S3Location{Endpoint: parsedUrl.Scheme + "://" + labels[1], VirtualHosted: true, Bucket: labels[0],
+				Key: strings.TrimPrefix(parsedUrl.Path, "/"), VersionId: parsedUrl.Query().Get("versionId")}, nil
+		}
+		parts := strings.SplitN(strings.TrimPrefix(parsedUrl.Path, "/"), "/", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("s3 url has no bucket: %s", parsedUrl)
+		}
+		location := &S3Location{Endpoint: parsedUrl.Scheme + "://" + parsedUrl.Host, Bucket: parts[0],
+			VersionId: parsedUrl.Query().Get("versionId")}
+		if len(parts) == 2 {
+			location.Key = parts[1]
+		}
+		return location, nil
+	}
+	return nil, errors.New("s3 url must have s3, http or https scheme")
+}
+
+// returns true, if location is a bucket root or a prefix ending with slash
+func (location *S3Location) IsPrefix() bool {
+	return location.Key == "" || strings.HasSuffix(location.Key, "/")
+}
+
+// returns url of another key in the same bucket, keeping url form of location
+func (location *S3Location) KeyUrl(key string) string {
+	return location.KeyVersionUrl(key, "")
+}
+
+// returns url of specific version of another key in the same bucket. Empty versionId means the latest version
+func (location *S3Location) KeyVersionUrl(key, versionId string) string {
+	var keyUrl *goUrl.URL
+	if location.Endpoint == "" {
+		keyUrl = &goUrl.URL{Scheme: "s3", Host: location.Bucket, Path: "/" + key}
+	} else if location.VirtualHosted {
+		keyUrl, _ = goUrl.Parse(location.Endpoint)
+		keyUrl.Host = location.Bucket + "." + keyUrl.Host
+		keyUrl.Path = "/" + key
+	} else {
+		keyUrl, _ = goUrl.Parse(location.Endpoint)
+		keyUrl.Path = "/" + location.Bucket + "/" + key
+	}
+	if versionId != "" {
+		keyUrl.RawQuery = goUrl.Values{"versionId": {versionId}}.Encode()
+	}
+	return keyUrl.String()
+}
+
+// returns S3 location parsed from destination url with addressing style of s3 options
+func (pd *ParsedDestination) GetS3Location() (*S3Location, error) {
+	return ParseS3Url(pd.ParsedUrl, pd.S3.GetAddressingStyle())
+}
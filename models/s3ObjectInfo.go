@@ -0,0 +1,25 @@
+package models
+
+// S3 specific metadata of remote file
+type S3ObjectInfo struct {
+	// STANDARD, STANDARD_IA, GLACIER, etc.
+	StorageClass string
+	// version id, if bucket versioning is enabled
+	VersionId string
+	// true for the current version of object. Filled by version listing
+	IsLatest bool
+	// true for delete marker version, which has no data
+	IsDeleteMarker bool
+	// user metadata (x-amz-meta-* headers) without prefix
+	Metadata map[string]string
+	// server-side encryption algorithm: AES256 or aws:kms
+	ServerSideEncryption string
+	// KMS key id for aws:kms encryption
+	SSEKMSKeyId string
+	// algorithm of encryption with customer-provided key (SSE-C)
+	SSECustomerAlgorithm string
+	// base64 encoded checksums of object data, if they were uploaded with object.
+	// Checksums of multipart objects have -<parts count> suffix
+	ChecksumCRC32C string
+	ChecksumSHA256 string
+}
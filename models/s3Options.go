@@ -0,0 +1,63 @@
+package models
+
+// S3 bucket addressing style
+type S3AddressingStyle string
+
+const (
+	// bucket is the first segment of path: https://endpoint/bucket/key
+	S3PathStyle S3AddressingStyle = "path"
+	// bucket is a subdomain of endpoint: https://bucket.endpoint/key
+	S3VirtualHostedStyle S3AddressingStyle = "virtual"
+)
+
+// S3 request signature version
+type S3SignatureVersion string
+
+const (
+	S3SignatureV4 S3SignatureVersion = "v4"
+	// legacy signature, supported by some S3 compatible storages only
+	S3SignatureV2 S3SignatureVersion = "v2"
+)
+
+// region used, if S3Options.Region is not set
+const DefaultS3Region = "ru-central1"
+
+// S3 connection settings. Allows to work with AWS, Yandex Object Storage and other S3 compatible storages
+type S3Options struct {
+	// bucket region. Defaults to DefaultS3Region
+	Region string
+	// endpoint url with scheme, i.e. "http://localhost:9000". Defaults to scheme and host of
+	// http(s)://endpoint/bucket/key Destination.Url. For s3://bucket/key urls, and if DualStack or
+	// Accelerate is set, defaults to AWS endpoint of Region
+	Endpoint string
+	// defaults to S3PathStyle, or to S3VirtualHostedStyle if Accelerate is set
+	AddressingStyle S3AddressingStyle
+	// use AWS dual-stack (IPv4 and IPv6) endpoint
+	DualStack bool
+	// use AWS S3 Transfer Acceleration endpoint. Requires virtual-hosted addressing style
+	Accelerate bool
+	// defaults to S3SignatureV4
+	SignatureVersion S3SignatureVersion
+	// size of ranged GET requests of download, bytes. Defaults to 5 MiB
+	PartSize int64
+	// number of parts downloaded in parallel. Defaults to 5
+	Concurrency int
+	// max bytes of ranged GET requests in flight, i.e. cap of PartSize×Concurrency. Concurrency is reduced to fit,
+	// must not be less than PartSize. It does not bound memory, parts are written to local file as they arrive
+	MaxInFlightBytes int64
+	// disables validation of downloaded data against ETag of single-part objects and CRC32C/SHA256 checksums
+	SkipChecksumValidation bool
+}
+
+// returns addressing style with its default. Options may be nil
+func (options *S3Options) GetAddressingStyle() S3AddressingStyle {
+	switch {
+	case options == nil:
+		return S3PathStyle
+	case options.AddressingStyle != "":
+		return options.AddressingStyle
+	case options.Accelerate:
+		return S3VirtualHostedStyle
+	}
+	return S3PathStyle
+}
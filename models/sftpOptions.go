@@ -0,0 +1,11 @@
+package models
+
+// SFTP browse and checksum settings
+type SftpOptions struct {
+	// stat targets of symbolic links, so that size, modification date and IsDir of the target are returned.
+	// Links with missing targets are returned with their own attributes
+	FollowSymlinks bool
+	// compute checksums with md5sum, sha1sum, sha256sum or sha512sum over ssh exec, when sftp server has no
+	// checksum extensions. Requires shell access to the server
+	ExecChecksum bool
+}
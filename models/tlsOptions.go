@@ -0,0 +1,116 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Declarative TLS settings. Unlike Credentials.TLSConfig they contain only plain values,
+// so they can be loaded from configuration files
+type TLSOptions struct {
+	// path to PEM file with CA certificates. Replaces system roots when set
+	CABundlePath string
+	// path to PEM client certificate for mutual TLS
+	ClientCertPath string
+	// path to PEM client private key for mutual TLS
+	ClientKeyPath string
+	// base64 encoded sha256 hashes of certificate SubjectPublicKeyInfo ("sha256/" prefix is allowed).
+	// Connection is accepted only if any certificate of the server chain matches one of the pins. Resumed
+	// sessions are checked too
+	SPKIPins []string
+	// disables server certificate chain and host name verification. Pins are still checked
+	InsecureSkipVerify bool
+	// overrides server name used for SNI and certificate verification
+	ServerName string
+}
+
+// Loads TLSOptions from json file
+func LoadTLSOptions(filePath string) (*TLSOptions, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	options := &TLSOptions{}
+	if err := json.Unmarshal(data, options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// Applies options on top of base config. Base config is not modified, nil base is allowed
+func (options *TLSOptions) BuildTLSConfig(base *tls.Config) (*tls.Config, error) {
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+
+	if options.CABundlePath != "" {
+		pem, err := ioutil.ReadFile(options.CABundlePath)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", options.CABundlePath)
+		}
+		config.RootCAs = pool
+	}
+
+	if options.ClientCertPath != "" || options.ClientKeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(options.ClientCertPath, options.ClientKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+
+	if options.ServerName != "" {
+		config.ServerName = options.ServerName
+	}
+
+	if options.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+
+	if len(options.SPKIPins) > 0 {
+		pins := make(map[string]bool, len(options.SPKIPins))
+		for _, pin := range options.SPKIPins {
+			pins[strings.TrimPrefix(pin, "sha256/")] = true
+		}
+		//VerifyPeerCertificate is not called on resumed sessions, so pins are checked for every connection
+		baseVerify := config.VerifyConnection
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if baseVerify != nil {
+				if err := baseVerify(state); err != nil {
+					return err
+				}
+			}
+			return verifySPKIPins(state.PeerCertificates, pins)
+		}
+	}
+
+	return config, nil
+}
+
+// Returns pin of certificate in the format expected by TLSOptions.SPKIPins
+func SPKIPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func verifySPKIPins(certs []*x509.Certificate, pins map[string]bool) error {
+	for _, cert := range certs {
+		if pins[SPKIPin(cert)] {
+			return nil
+		}
+	}
+	return fmt.Errorf("no certificate of the server chain matches SPKI pins")
+}
@@ -2,6 +2,15 @@ package models
 
 import "fmt"
 
+// UnaError codes
+const (
+	// remote file or dir does not exist
+	ErrCodeNotFound = 404
+)
+
+// Sentinel for errors.Is checks: errors.Is(err, models.ErrNotFound)
+var ErrNotFound = &UnaError{Code: ErrCodeNotFound, Message: "not found"}
+
 type UnaError struct {
 	Code    int
 	Message string
@@ -10,3 +19,14 @@ type UnaError struct {
 func (e *UnaError) Error() string {
 	return fmt.Sprintf("%d:%s", e.Code, e.Message)
 }
+
+// UnaErrors with the same Code match each other in errors.Is
+func (e *UnaError) Is(target error) bool {
+	t, ok := target.(*UnaError)
+	return ok && t.Code == e.Code
+}
+
+// returns not found error for remote file or dir url
+func NewNotFoundError(url string) *UnaError {
+	return &UnaError{Code: ErrCodeNotFound, Message: "not found: " + url}
+}
@@ -0,0 +1,115 @@
+package ftp
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Algorithm names of HASH command (draft-bryan-ftpext-hash)
+var ftpHashNames = map[models.ChecksumAlgorithm]string{
+	models.ChecksumMD5:    "MD5",
+	models.ChecksumSHA1:   "SHA-1",
+	models.ChecksumSHA256: "SHA-256",
+	models.ChecksumSHA512: "SHA-512",
+	models.ChecksumCRC32:  "CRC32",
+}
+
+//Legacy checksum commands of servers without HASH, i.e. "XMD5 file.txt" - "250 0BF6FC53AC1BA2DE03FBD6B7A4A5E7C8"
+var ftpLegacyHashCommands = map[models.ChecksumAlgorithm]string{
+	models.ChecksumMD5:   "XMD5",
+	models.ChecksumCRC32: "XCRC",
+}
+
+//Hex length of checksums of legacy commands
+var ftpChecksumLengths = map[models.ChecksumAlgorithm]int{
+	models.ChecksumMD5:   32,
+	models.ChecksumCRC32: 8,
+}
+
+//Get checksum of the file, computed by server with HASH, or with XMD5 and XCRC on servers without HASH
+func (ftpDownloader *FtpDownloader) Checksum(remoteFile *models.RemoteFile, algorithm models.ChecksumAlgorithm) (string, error) {
+	ftpClient, err := ftpDownloader.getClient(remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+
+	defer ftpClient.Close()
+
+	return ftpDownloader.checksum(ftpClient, remoteFile, algorithm)
+}
+
+func (ftpDownloader *FtpDownloader) checksum(client IFtpClient, remoteFile *models.RemoteFile, algorithm models.ChecksumAlgorithm) (string, error) {
+	session, err := ftpDownloader.openSession(client, remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	checksum, err := session.checksum(path.Join(remoteFile.Path, remoteFile.Name), algorithm)
+	var replyErr *replyError
+	if errors.As(err, &replyErr) && replyErr.code == replyFileUnavailable {
+		return "", models.NewNotFoundError(remoteFile.ParsedDestination.Url)
+	}
+	return checksum, err
+}
+
+func (session *ftpSession) checksum(filePath string, algorithm models.ChecksumAlgorithm) (string, error) {
+	if name, ok := ftpHashNames[algorithm]; ok {
+		checksum, err := session.hash(filePath, name)
+		var replyErr *replyError
+		if err == nil || !errors.As(err, &replyErr) || !commandNotSupported(replyErr.code) {
+			return checksum, err
+		}
+	}
+
+	command, ok := ftpLegacyHashCommands[algorithm]
+	if !ok {
+		return "", fmt.Errorf("ftp server does not support %s checksum", algorithm)
+	}
+	code, msg, err := session.send(command, filePath)
+	if err != nil {
+		return "", err
+	}
+	if code != replyFileActionOk && code != replyFileStatus {
+		if commandNotSupported(code) {
+			return "", fmt.Errorf("ftp server does not support %s checksum: %d %s", algorithm, code, msg)
+		}
+		return "", &replyError{command: command + " " + filePath, code: code, message: msg}
+	}
+	//some servers reply with file name or range before checksum
+	for _, field := range strings.Fields(msg) {
+		if len(field) == ftpChecksumLengths[algorithm] && isHex(field) {
+			return strings.ToLower(field), nil
+		}
+	}
+	return "", fmt.Errorf("invalid ftp %s reply: %s", command, msg)
+}
+
+//Selects algorithm with OPTS HASH and sends HASH. Reply is "213 SHA-256 0-49 169cd22282da7f147cb491e559e9dd filename"
+func (session *ftpSession) hash(filePath, name string) (string, error) {
+	if err := session.command(replyCommandOk, "OPTS HASH", name); err != nil {
+		return "", err
+	}
+	code, msg, err := session.send("HASH", filePath)
+	if err != nil {
+		return "", err
+	}
+	if code != replyFileStatus {
+		return "", &replyError{command: "HASH " + filePath, code: code, message: msg}
+	}
+	fields := strings.Fields(msg)
+	if len(fields) < 3 || !strings.EqualFold(fields[0], name) || !isHex(fields[2]) {
+		return "", fmt.Errorf("invalid ftp HASH reply: %s", msg)
+	}
+	return strings.ToLower(fields[2]), nil
+}
+
+func isHex(value string) bool {
+	_, err := hex.DecodeString(value)
+	return err == nil && value != ""
+}
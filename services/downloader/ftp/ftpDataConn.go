@@ -0,0 +1,364 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"github.com/secsy/goftp"
+	"golang.org/x/text/encoding"
+)
+
+//FTP reply codes of data transfers
+const (
+	replyDataConnectionOpen          = 125
+	replyAboutToOpenDataConnection   = 150
+	replyCommandOk                   = 200
+	replyClosingDataConnection       = 226
+	replyEnteringPassiveMode         = 227
+	replyEnteringExtendedPassiveMode = 229
+)
+
+//Returned, when server rejects a command
+type replyError struct {
+	command string
+	code    int
+	message string
+}
+
+func (e *replyError) Error() string {
+	return fmt.Sprintf("ftp %s failed: %d %s", e.command, e.code, e.message)
+}
+
+//Control connection, which opens data connections itself and encodes file names. Goftp can neither force EPSV
+//nor replace address advertised in PASV reply, so commands and data transfers are made over raw connection
+type ftpSession struct {
+	conn    goftp.RawConn
+	options models.FtpOptions
+	//encoding of file names, nil for UTF-8
+	encoding encoding.Encoding
+	//IP address of server, control connection is made to
+	controlIP string
+	//data connections are protected with TLS, if set. It shares session cache with control connections
+	tlsConfig        *tls.Config
+	timeout          time.Duration
+	epsvNotSupported bool
+}
+
+//Data connection, which is either dialed already (passive mode) or is accepted after transfer command (active mode)
+type pendingDataConn struct {
+	conn     net.Conn
+	listener *net.TCPListener
+}
+
+//Data connection with i/o timeout
+type dataConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *dataConn) Read(buf []byte) (int, error) {
+	if c.timeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	return c.Conn.Read(buf)
+}
+
+func (ftpDownloader *FtpDownloader) openSession(client IFtpClient, destination *models.ParsedDestination) (*ftpSession, error) {
+	options, err := getFtpOptions(destination)
+	if err != nil {
+		return nil, err
+	}
+	controlIP, _, err := net.SplitHostPort(client.ControlAddr())
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.OpenRawConn()
+	if err != nil {
+		return nil, err
+	}
+	session := &ftpSession{conn: conn, options: options, encoding: ftpEncodings[options.Encoding], controlIP: controlIP,
+		timeout: destination.Timeout}
+	if tlsConfig := client.TLSConfig(); tlsConfig != nil {
+		if options.DataProtection == models.FtpPrivateProtection {
+			session.tlsConfig = tlsConfig
+		}
+		//protection level is set by control connections, which send CCC, during login
+		if !options.ClearControlChannel {
+			if err := session.protect(destination.Credentials.TLSMode); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+	}
+
+	if options.NegotiateUTF8 {
+		//rejection is not an error: such servers either use UTF-8 already or do not support it at all
+		if _, _, err := session.send("OPTS UTF8 ON", ""); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+//Goftp sends PBSZ 0 and PROT P after AUTH TLS only, so protection level is set here for implicit TLS and for
+//clear data connections
+func (session *ftpSession) protect(mode models.TLSMode) error {
+	if mode == models.TLSImplicit {
+		if err := session.command(replyCommandOk, "PBSZ", "0"); err != nil {
+			return err
+		}
+	} else if session.options.DataProtection == models.FtpPrivateProtection {
+		return nil
+	}
+	return session.command(replyCommandOk, "PROT", string(session.options.DataProtection))
+}
+
+func (session *ftpSession) Close() error {
+	return session.conn.Close()
+}
+
+//Sends command with optional argument, which is encoded with encoding of file names. Reply is decoded
+func (session *ftpSession) send(command, argument string) (int, string, error) {
+	line := command
+	if argument != "" {
+		encoded, err := encodeName(session.encoding, argument)
+		if err != nil {
+			return 0, "", err
+		}
+		line += " " + encoded
+	}
+	code, msg, err := session.conn.SendCommand("%s", line)
+	return code, decodeName(session.encoding, msg), err
+}
+
+//Sends command, which does not open data connection, and checks reply code
+func (session *ftpSession) command(expected int, command, argument string) error {
+	code, msg, err := session.send(command, argument)
+	if err != nil {
+		return err
+	}
+	if code != expected {
+		return &replyError{command: strings.TrimSpace(command + " " + argument), code: code, message: msg}
+	}
+	return nil
+}
+
+//Sends command, which transfers data over data connection (RETR, LIST, MLSD), and passes received data to read
+func (session *ftpSession) transfer(command, argument string, read func(io.Reader) error) error {
+	pending, err := session.prepareDataConn()
+	if err != nil {
+		return err
+	}
+	defer pending.close()
+
+	code, msg, err := session.send(command, argument)
+	if err != nil {
+		return err
+	}
+	if code != replyDataConnectionOpen && code != replyAboutToOpenDataConnection {
+		return &replyError{command: strings.TrimSpace(command + " " + argument), code: code, message: msg}
+	}
+
+	conn, err := pending.open(session.timeout)
+	if err != nil {
+		return err
+	}
+	if session.tlsConfig != nil {
+		conn = tls.Client(conn, session.tlsConfig)
+	}
+	readErr := read(&dataConn{Conn: conn, timeout: session.timeout})
+	//server sends final reply after data connection is closed
+	conn.Close()
+	pending.close()
+
+	code, msg, err = session.conn.ReadResponse()
+	if readErr != nil {
+		return readErr
+	}
+	if err != nil {
+		return err
+	}
+	if code != replyClosingDataConnection && code != replyFileActionOk {
+		return &replyError{command: strings.TrimSpace(command + " " + argument), code: code, message: decodeName(session.encoding, msg)}
+	}
+	return nil
+}
+
+func (session *ftpSession) prepareDataConn() (*pendingDataConn, error) {
+	switch session.options.DataConnectionMode {
+	case models.FtpActiveMode:
+		return session.listenActive()
+	default:
+		return session.dialPassive()
+	}
+}
+
+//Requests EPSV or PASV according to data connection mode and dials data connection
+func (session *ftpSession) dialPassive() (*pendingDataConn, error) {
+	mode := session.options.DataConnectionMode
+	addr := ""
+	if mode != models.FtpPASVMode && !session.epsvNotSupported {
+		code, msg, err := session.conn.SendCommand("EPSV")
+		if err != nil {
+			return nil, err
+		}
+		if code == replyEnteringExtendedPassiveMode {
+			port, err := parseEpsvReply(msg)
+			if err != nil {
+				return nil, err
+			}
+			//EPSV reply has no address, data connection is made to the server of control connection
+			addr = net.JoinHostPort(session.controlIP, strconv.Itoa(port))
+		} else if mode == models.FtpEPSVMode {
+			return nil, &replyError{command: "EPSV", code: code, message: msg}
+		} else {
+			session.epsvNotSupported = true
+		}
+	}
+
+	if addr == "" {
+		code, msg, err := session.conn.SendCommand("PASV")
+		if err != nil {
+			return nil, err
+		}
+		if code != replyEnteringPassiveMode {
+			return nil, &replyError{command: "PASV", code: code, message: msg}
+		}
+		ip, port, err := parsePasvReply(msg)
+		if err != nil {
+			return nil, err
+		}
+		if session.options.UseControlIPForPASV {
+			ip = session.controlIP
+		}
+		addr = net.JoinHostPort(ip, strconv.Itoa(port))
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, session.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &pendingDataConn{conn: conn}, nil
+}
+
+//Listens for data connection and sends its address with PORT or EPRT
+func (session *ftpSession) listenActive() (*pendingDataConn, error) {
+	host, port := "", "0"
+	if listenAddr := session.options.ActiveListenAddr; listenAddr != "" {
+		var err error
+		host, port, err = net.SplitHostPort(listenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ftp active listen address %s: %v", listenAddr, err)
+		}
+	}
+	tcpAddr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	//server must be told an address it can connect to, not the unspecified one
+	listenAddr := listener.Addr().(*net.TCPAddr)
+	ip := listenAddr.IP
+	if ip == nil || ip.IsUnspecified() {
+		if ip, err = session.localIP(); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+
+	command, argument := "PORT", ""
+	if ipv4 := ip.To4(); ipv4 != nil {
+		argument = fmt.Sprintf("%d,%d,%d,%d,%d,%d", ipv4[0], ipv4[1], ipv4[2], ipv4[3], listenAddr.Port>>8, listenAddr.Port&0xFF)
+	} else {
+		command, argument = "EPRT", fmt.Sprintf("|2|%s|%d|", ip, listenAddr.Port)
+	}
+	if err := session.command(replyCommandOk, command, argument); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &pendingDataConn{listener: listener}, nil
+}
+
+//Local IP address, which is routed to the server of control connection
+func (session *ftpSession) localIP() (net.IP, error) {
+	//udp "connection" sends nothing, it only selects route
+	conn, err := net.Dial("udp", net.JoinHostPort(session.controlIP, "21"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+func (pending *pendingDataConn) open(timeout time.Duration) (net.Conn, error) {
+	if pending.conn != nil {
+		return pending.conn, nil
+	}
+	if timeout > 0 {
+		pending.listener.SetDeadline(time.Now().Add(timeout))
+	}
+	conn, err := pending.listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	pending.conn = conn
+	return conn, nil
+}
+
+func (pending *pendingDataConn) close() {
+	if pending.listener != nil {
+		pending.listener.Close()
+		pending.listener = nil
+	}
+	if pending.conn != nil {
+		pending.conn.Close()
+		pending.conn = nil
+	}
+}
+
+//Parses "Entering Extended Passive Mode (|||6446|)"
+func parseEpsvReply(msg string) (int, error) {
+	start, end := strings.Index(msg, "|||"), strings.LastIndex(msg, "|")
+	if start < 0 || start+3 > end {
+		return 0, fmt.Errorf("invalid ftp EPSV reply: %s", msg)
+	}
+	port, err := strconv.Atoi(msg[start+3 : end])
+	if err != nil || port <= 0 || port > 0xFFFF {
+		return 0, fmt.Errorf("invalid ftp EPSV reply: %s", msg)
+	}
+	return port, nil
+}
+
+//Parses "Entering Passive Mode (192,168,1,2,195,149)"
+func parsePasvReply(msg string) (string, int, error) {
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || start > end {
+		return "", 0, fmt.Errorf("invalid ftp PASV reply: %s", msg)
+	}
+	parts := strings.Split(msg[start+1:end], ",")
+	if len(parts) != 6 {
+		return "", 0, fmt.Errorf("invalid ftp PASV reply: %s", msg)
+	}
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		number, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || number < 0 || number > 0xFF {
+			return "", 0, fmt.Errorf("invalid ftp PASV reply: %s", msg)
+		}
+		numbers[i] = number
+	}
+	ip := net.IPv4(byte(numbers[0]), byte(numbers[1]), byte(numbers[2]), byte(numbers[3]))
+	return ip.String(), numbers[4]<<8 | numbers[5], nil
+}
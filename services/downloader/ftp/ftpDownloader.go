@@ -3,8 +3,11 @@
 package ftp
 
 import (
+	"crypto/tls"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
 
@@ -12,7 +15,15 @@ import (
 	"github.com/secsy/goftp"
 )
 
+//Scheme of FTP over TLS
+const ftpsScheme = "ftps"
+
 type FtpDownloader struct {
+	//Custom LIST line parsers. Tried by Browse before the default ones
+	ListingParsers []ListingParser
+	//Called with url of directory and lines of its listing, which were not parsed by Browse or RemoveRecursive.
+	//Parsed files are returned without error anyway
+	OnListingWarnings func(url string, warnings []ListingWarning)
 }
 
 type IFtpClient interface {
@@ -21,8 +32,38 @@ type IFtpClient interface {
 	Delete(path string) error
 	Rmdir(path string) error
 	Stat(path string) (os.FileInfo, error)
+	OpenRawConn() (goftp.RawConn, error)
+	ControlAddr() string
+	TLSConfig() *tls.Config
+}
+
+//Goftp client, which remembers resolved address of the server and TLS config of its connections
+type ftpClient struct {
+	*goftp.Client
+	controlAddr string
+	tlsConfig   *tls.Config
+	//set, if control connections are made by the downloader itself to send CCC after login
+	clearControl *clearControlConfig
+}
+
+//Returns "ip:port" of the server, control connections are made to
+func (client *ftpClient) ControlAddr() string {
+	return client.controlAddr
+}
+
+//Returns TLS config of control connections, which data connections share TLS session cache with. Nil for plain FTP
+func (client *ftpClient) TLSConfig() *tls.Config {
+	return client.tlsConfig
+}
+
+func (client *ftpClient) OpenRawConn() (goftp.RawConn, error) {
+	if client.clearControl != nil {
+		return dialClearControlConn(client.controlAddr, client.clearControl)
+	}
+	return client.Client.OpenRawConn()
 }
 
+//Get file or directory info. Returns not found error, if path does not exist
 func (ftpDownloader *FtpDownloader) Stat(destination *models.ParsedDestination) (*models.RemoteFile, error) {
 	ftpClient, err := ftpDownloader.getClient(destination)
 	if err != nil {
@@ -30,21 +71,11 @@ func (ftpDownloader *FtpDownloader) Stat(destination *models.ParsedDestination)
 	}
 	defer ftpClient.Close()
 
-	filePath := destination.GetPath()
-	_, fileName := path.Split(filePath)
-
-	remoteFiles, err := ftpDownloader.browse(ftpClient, destination)
-	var remoteFile *models.RemoteFile
-	for _, entry := range remoteFiles {
-		if entry.Name == fileName {
-			remoteFile = entry
-			break
-		}
-	}
-	return remoteFile, err
+	return ftpDownloader.stat(ftpClient, destination)
 }
 
-//Browse a files list in the server directory
+//Browse a files list in the server directory (MLSD, unix, DOS/IIS, EPLF or format of custom ListingParsers).
+//Lines of listing, which were not parsed, are skipped and reported to OnListingWarnings
 func (ftpDownloader *FtpDownloader) Browse(destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
 	ftpClient, err := ftpDownloader.getClient(destination)
 	if err != nil {
@@ -79,14 +110,35 @@ func (ftpDownloader *FtpDownloader) Remove(remoteFile *models.RemoteFile) error
 }
 
 func (ftpDownloader *FtpDownloader) download(ftpClient IFtpClient, remoteFile *models.RemoteFile) (*models.RemoteFileContent, error) {
+	session, err := ftpDownloader.openSession(ftpClient, remoteFile.ParsedDestination)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
 	localFile, err := ioutil.TempFile("", remoteFile.Name+".*")
 	if err != nil {
 		return nil, err
 	}
 	defer localFile.Close()
 
-	err = ftpClient.Retrieve(path.Join(remoteFile.Path, remoteFile.Name), localFile)
+	err = session.command(replyCommandOk, "TYPE", string(session.options.TransferType))
+	if err == nil {
+		err = session.transfer("RETR", path.Join(remoteFile.Path, remoteFile.Name), func(data io.Reader) error {
+			if session.options.TransferType != models.FtpASCIIType {
+				_, err := io.Copy(localFile, data)
+				return err
+			}
+			writer := &crlfWriter{writer: localFile}
+			if _, err := io.Copy(writer, data); err != nil {
+				return err
+			}
+			return writer.Flush()
+		})
+	}
 	if err != nil {
+		localFile.Close()
+		os.Remove(localFile.Name())
 		return nil, err
 	}
 
@@ -99,51 +151,174 @@ func (ftpDownloader *FtpDownloader) download(ftpClient IFtpClient, remoteFile *m
 	}, nil
 }
 
-//In credentials use only TLSConfig and TLSMode
-func (ftpDownloader *FtpDownloader) getClient(destination *models.ParsedDestination) (*goftp.Client, error) {
-	host := destination.GetHost()
+//In credentials use only TLSConfig, TLSOptions and TLSMode. ftps:// URLs use TLS even without TLSConfig
+func (ftpDownloader *FtpDownloader) getClient(destination *models.ParsedDestination) (*ftpClient, error) {
 	user := destination.GetUser()
 	password := destination.GetPassword()
 
+	options, err := getFtpOptions(destination)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := getTLSConfig(destination, options)
+	if err != nil {
+		return nil, err
+	}
+	//the address is resolved once, so that data connections are made to the same server as control connections
+	controlAddr, err := resolveControlAddr(destination)
+	if err != nil {
+		return nil, err
+	}
+
 	config := goftp.Config{
 		Timeout:   destination.Timeout,
 		User:      user,
 		Password:  password,
-		TLSConfig: destination.Credentials.TLSConfig,
+		TLSConfig: tlsConfig,
 		TLSMode:   goftp.TLSMode(destination.Credentials.TLSMode),
 	}
-	client, err := goftp.DialConfig(config, host)
+	client, err := goftp.DialConfig(config, controlAddr)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return client, nil
+	result := &ftpClient{Client: client, controlAddr: controlAddr, tlsConfig: tlsConfig}
+	if options.ClearControlChannel {
+		result.clearControl = &clearControlConfig{Config: config, dataProtection: options.DataProtection}
+	}
+	return result, nil
+}
+
+//goftp dials resolved IP addresses, so server name must be set for certificate verification. Config of ftps://
+//URLs defaults to the verifying one. Session cache is added for TLSSessionReuse
+func getTLSConfig(destination *models.ParsedDestination, options models.FtpOptions) (*tls.Config, error) {
+	tlsConfig, err := destination.GetTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil && destination.GetScheme() == ftpsScheme {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig == nil {
+		if options.ClearControlChannel {
+			return nil, fmt.Errorf("ftp CCC can not be used without TLS")
+		}
+		return nil, nil
+	}
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = destination.ParsedUrl.Hostname()
+	}
+	if options.TLSSessionReuse && tlsConfig.ClientSessionCache == nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	return tlsConfig, nil
+}
+
+func getFtpOptions(destination *models.ParsedDestination) (models.FtpOptions, error) {
+	options := models.FtpOptions{}
+	if destination.Ftp != nil {
+		options = *destination.Ftp
+	}
+	switch options.DataConnectionMode {
+	case "":
+		options.DataConnectionMode = models.FtpPassiveMode
+	case models.FtpPassiveMode, models.FtpEPSVMode, models.FtpPASVMode, models.FtpActiveMode:
+	default:
+		return options, fmt.Errorf("unknown ftp data connection mode: %s", options.DataConnectionMode)
+	}
+	switch options.TransferType {
+	case "":
+		options.TransferType = models.FtpBinaryType
+	case models.FtpBinaryType, models.FtpASCIIType:
+	default:
+		return options, fmt.Errorf("unknown ftp transfer type: %s", options.TransferType)
+	}
+	switch options.DataProtection {
+	case "":
+		options.DataProtection = models.FtpPrivateProtection
+	case models.FtpPrivateProtection, models.FtpClearProtection:
+	default:
+		return options, fmt.Errorf("unknown ftp data protection level: %s", options.DataProtection)
+	}
+	switch options.Encoding {
+	case "":
+		options.Encoding = models.FtpUTF8
+	case models.FtpUTF8:
+	default:
+		if _, ok := ftpEncodings[options.Encoding]; !ok {
+			return options, fmt.Errorf("unknown ftp file names encoding: %s", options.Encoding)
+		}
+		if options.NegotiateUTF8 {
+			return options, fmt.Errorf("ftp UTF8 negotiation can not be used with %s encoding", options.Encoding)
+		}
+	}
+	return options, nil
+}
+
+//Resolves host of destination to "ip:port". IPv4 addresses are preferred, as goftp does. Port defaults to 990
+//for implicit TLS
+func resolveControlAddr(destination *models.ParsedDestination) (string, error) {
+	host, port := destination.ParsedUrl.Hostname(), destination.ParsedUrl.Port()
+	if port == "" {
+		port = "21"
+		if destination.Credentials.TLSMode == models.TLSImplicit {
+			port = "990"
+		}
+	}
+	if net.ParseIP(host) != nil {
+		return net.JoinHostPort(host, port), nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("ftp host %s has no addresses", host)
+	}
+	ip := ips[0]
+	for _, candidate := range ips {
+		if candidate.To4() != nil {
+			ip = candidate
+			break
+		}
+	}
+	return net.JoinHostPort(ip.String(), port), nil
 }
 
 func (ftpDownloader *FtpDownloader) browse(client IFtpClient, destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
-	entryList, err := client.ReadDir(destination.GetPath())
-	result := make([]*models.RemoteFile, 0)
+	session, err := ftpDownloader.openSession(client, destination)
 	if err != nil {
 		return nil, err
 	}
-	for _, entry := range entryList {
-		result = append(result, &models.RemoteFile{Name: entry.Name(), Path: destination.GetPath(), Size: entry.Size(),
-			ParsedDestination: destination, Lastmod: entry.ModTime(), IsDir: entry.IsDir()})
+	defer session.Close()
+
+	files, warnings, err := session.list(destination, ftpDownloader.listingParsers())
+	if err != nil {
+		return nil, err
 	}
-	return result, nil
+	ftpDownloader.reportListingWarnings(destination, warnings)
+	return files, nil
 }
 
 func (ftpDownloader *FtpDownloader) remove(client IFtpClient, remoteFile *models.RemoteFile) error {
+	session, err := ftpDownloader.openSession(client, remoteFile.ParsedDestination)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	//files of Browse have destination of their directory
 	filePath := path.Join(remoteFile.Path, remoteFile.Name)
-	stat, err := client.Stat(filePath)
+	stat, err := session.stat(withPath(remoteFile.ParsedDestination, filePath))
 	if err != nil {
 		return err
 	}
-	if stat.IsDir() {
-		return client.Rmdir(filePath)
+	if stat.IsDir {
+		return session.command(replyFileActionOk, "RMD", filePath)
 	}
-	return client.Delete(filePath)
+	return session.command(replyFileActionOk, "DELE", filePath)
 }
 
 //type FtpResponse interface {
@@ -1,38 +1,32 @@
 package ftp
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/fclairamb/ftpserver/server"
 	"github.com/secsy/goftp"
 
-	"github.com/bouk/monkey"
 	"github.com/goodsru/go-universal-network-adapter/models"
 	"github.com/goodsru/go-universal-network-adapter/tests/ftpServerDriver"
 	assertLib "github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
 )
 
 const (
 	ServerIP = "127.0.0.1:2121"
 )
 
-type mockedFileInfo struct {
-	os.FileInfo
-	size    int64
-	name    string
-	modTime time.Time
-}
-
-func (m mockedFileInfo) Size() int64        { return m.size }
-func (m mockedFileInfo) Name() string       { return m.name }
-func (m mockedFileInfo) ModTime() time.Time { return m.modTime }
-
 func CreateDirIfNotExist(dir string) error {
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		err = os.MkdirAll(dir, 0755)
@@ -45,61 +39,71 @@ func TestFtpDownloaderUnit(t *testing.T) {
 	assertions := assertLib.New(t)
 	ftpDownloader := &FtpDownloader{}
 
-	client := new(goftp.Client)
-
 	t.Run("FtpMockedBrowseReturnsListAndNoError", func(t *testing.T) {
-		monkey.UnpatchAll()
-		monkey.Patch((*goftp.Client).ReadDir, func(client *goftp.Client, destination string) ([]os.FileInfo, error) {
-			return []os.FileInfo{
-				&mockedFileInfo{name: "1.jpg"},
-				&mockedFileInfo{name: "2.jpg"},
-				&mockedFileInfo{name: "3.jpg"},
-				&mockedFileInfo{name: "4.jpg"},
-			}, nil
-		})
-
+		client := &fakeFtpClient{conn: &fakeRawConn{
+			replies: map[string]string{"CWD /root": "250 Directory changed"},
+			data: map[string]string{"MLSD": "type=file;size=10;modify=20200101120000; 1.jpg\r\n" +
+				"type=file;size=20;modify=20200101120000; 2.jpg\r\n" +
+				"type=file;size=30;modify=20200101120000; 3.jpg\r\n" +
+				"type=dir;modify=20200101120000; 4.jpg\r\n"},
+		}}
 		parsedDest, _ := models.ParseDestination(&models.Destination{Url: "ftp://ftp.com/root"})
 
 		list, err := ftpDownloader.browse(client, parsedDest)
 
 		assertions.Nil(err, fmt.Sprintf("err == %v, expected - nil", err))
-		assertions.Len(list, 4, fmt.Sprintf("found %v files, expected 4 files", len(list)))
-		assertions.Equal(list[0].Name, "1.jpg", fmt.Sprintf("File name %v, expected %v", list[0].Name, "1.jpg"))
-		assertions.Equal(list[3].Name, "4.jpg", fmt.Sprintf("File name %v, expected %v", list[0].Name, "4.jpg"))
+		if !assertions.Len(list, 4, fmt.Sprintf("found %v files, expected 4 files", len(list))) {
+			return
+		}
+		assertions.Equal("1.jpg", list[0].Name, fmt.Sprintf("File name %v, expected %v", list[0].Name, "1.jpg"))
+		assertions.Equal(int64(10), list[0].Size)
+		assertions.Equal("4.jpg", list[3].Name, fmt.Sprintf("File name %v, expected %v", list[3].Name, "4.jpg"))
+		assertions.True(list[3].IsDir)
+	})
+
+	t.Run("FtpMockedRemoveBrowsedFileSendsDele", func(t *testing.T) {
+		conn := &fakeRawConn{
+			replies: map[string]string{
+				"CWD /root":        "250 Directory changed",
+				"MLST /root/1.jpg": "250-Listing /root/1.jpg\n type=file;size=10; /root/1.jpg\n250 End",
+				"DELE /root/1.jpg": "250 File deleted",
+			},
+			data: map[string]string{"MLSD": "type=file;size=10;modify=20200101120000; 1.jpg\r\n"},
+		}
+		client := &fakeFtpClient{conn: conn}
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: "ftp://ftp.com/root"})
+		list, err := ftpDownloader.browse(client, parsedDest)
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) || !assertions.Len(list, 1) {
+			return
+		}
+		conn.commands = nil
+
+		err = ftpDownloader.remove(client, list[0])
+
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal([]string{"MLST /root/1.jpg", "DELE /root/1.jpg"}, conn.commands)
 	})
 
 	t.Run("FtpMockedBrowseNonExistingFolderReturnsError", func(t *testing.T) {
+		client := &fakeFtpClient{conn: &fakeRawConn{replies: map[string]string{"CWD /dir123": "550 No such directory"}}}
 		parsedDest, _ := models.ParseDestination(&models.Destination{Url: "ftp://ftp.com/dir123"})
 
-		errText := "unknown directory"
-		monkey.UnpatchAll()
-		monkey.Patch((*goftp.Client).ReadDir, func(client *goftp.Client, destination string) ([]os.FileInfo, error) {
-			return nil, fmt.Errorf(errText)
-		})
-
 		list, err := ftpDownloader.browse(client, parsedDest)
 
-		assertions.NotNil(err)
+		assertions.True(errors.Is(err, models.ErrNotFound), fmt.Sprintf("err == %v, expected - not found", err))
 		assertions.Nil(list)
-		assertions.Equal(errText, err.Error())
 	})
 
 	t.Run("FtpMockedDownloadReturnsCorrectFile", func(t *testing.T) {
 		fileName := "test.txt"
 		expectedResult := "hello world"
-
+		client := &fakeFtpClient{conn: &fakeRawConn{
+			replies: map[string]string{"TYPE I": "200 Type set to I"},
+			data:    map[string]string{"RETR /files/" + fileName: expectedResult},
+		}}
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: "ftp://sftp.com:22/files/" + fileName})
 
-		monkey.UnpatchAll()
-		monkey.Patch(goftp.DialConfig, func(goftp.Config, ...string) (*goftp.Client, error) {
-			return new(goftp.Client), nil
-		})
-		monkey.Patch((*goftp.Client).Retrieve, func(client *goftp.Client, filePath string, dest io.Writer) error {
-			_, err := dest.Write([]byte(expectedResult))
-			return err
-		})
-
-		fileContent, err := ftpDownloader.Download(remoteFile)
+		fileContent, err := ftpDownloader.download(client, remoteFile)
 
 		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
 			return
@@ -117,21 +121,18 @@ func TestFtpDownloaderUnit(t *testing.T) {
 
 	t.Run("FtpMockedDownloadNonExistingFileReturnsError", func(t *testing.T) {
 		fileName := "test.txt"
+		client := &fakeFtpClient{conn: &fakeRawConn{
+			replies: map[string]string{"TYPE I": "200 Type set to I", "RETR /files/" + fileName: "550 incorrect file"},
+			data:    map[string]string{},
+		}}
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: "ftp://sftp.com:22/files/" + fileName})
 
-		errText := "incorrect file"
-		monkey.UnpatchAll()
-		monkey.Patch((*goftp.Client).Retrieve, func(client *goftp.Client, filePath string, dest io.Writer) error {
-			return fmt.Errorf(errText)
-		})
-
 		result, err := ftpDownloader.download(client, remoteFile)
 
 		assertions.NotNil(err)
-		assertions.Contains(err.Error(), errText)
+		assertions.Contains(err.Error(), "incorrect file")
 		assertions.Nil(result)
 	})
-	monkey.UnpatchAll()
 }
 
 func Test_Locale_TLS(t *testing.T) {
@@ -296,65 +297,192 @@ func Test_Locale_TLS(t *testing.T) {
 	//BasicAuth/////////////////////////////////////////////////////////////////\
 
 	//TLSExplicit/////////////////////////////////////////////////////////////////
-	//t.Run("FtpBrowseReturnsCorrectListTLSExplicit", func(t *testing.T) {
-	//	parsedDest, err := models.ParseDestination(&models.Destination{
-	//		Url: "ftp://" + ServerIP + "/",
-	//		Credentials: &models.Credentials{
-	//			User:     "test",
-	//			Password: "test",
-	//			TLSConfig: &tls.Config{
-	//				InsecureSkipVerify: true,
-	//				ClientAuth:         tls.RequestClientCert,
-	//				ClientSessionCache : tls.NewLRUClientSessionCache(0),
-	//			},
-	//			TLSMode: models.TLSExplicit,
-	//		},
-	//	})
-	//
-	//	list, err := ftpDownloader.Browse(parsedDest)
-	//	assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
-	//	fileFound := false
-	//	for _, remoteFile := range list {
-	//		if fileFound = remoteFile.Name == fileName; fileFound {
-	//			break
-	//		}
-	//	}
-	//	assertions.True(fileFound, "File must be found")
-	//})
-	//
-	////FtpDownload_ReturnsCorrectRemoteFile_TLSExplicit - old name
-	//ftpDownloader = FtpDownloader{}
-	//t.Run("FtpDownloadReturnsCorrectRemoteFileTLSExplicit", func(t *testing.T) {
-	//	expectedResult := fileData
-	//	remoteFile, _ := models.NewRemoteFile(&models.Destination{
-	//		Url: "ftp://" + ServerIP + "/" + fileName,
-	//		Credentials: &models.Credentials{
-	//			User:     "test",
-	//			Password: "test",
-	//			TLSConfig: &tls.Config{
-	//				InsecureSkipVerify: true,
-	//				ClientAuth:         tls.RequestClientCert,
-	//				ClientSessionCache : tls.NewLRUClientSessionCache(0),
-	//			},
-	//			TLSMode: models.TLSExplicit,
-	//		},
-	//	})
-	//
-	//	result, err := ftpDownloader.Download(remoteFile)
-	//
-	//	if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
-	//		return
-	//	}
-	//	assertions.Equal(fileName, result.Name, fmt.Sprintf("Received file name %v, expected - %v", result.Name, fileName))
-	//
-	//	blobBytes, err := ioutil.ReadAll(result.Blob)
-	//	assertions.Equal(expectedResult, string(blobBytes))
-	//
-	//	err = result.Blob.Close()
-	//	assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
-	//
-	//})
+	t.Run("FtpBrowseReturnsCorrectListTLSExplicit", func(t *testing.T) {
+		parsedDest, err := models.ParseDestination(&models.Destination{
+			Url: "ftp://" + ServerIP + "/",
+			Credentials: &models.Credentials{
+				User:     "test",
+				Password: "test",
+				TLSConfig: &tls.Config{
+					InsecureSkipVerify: true,
+					ClientSessionCache: tls.NewLRUClientSessionCache(0),
+				},
+				TLSMode: models.TLSExplicit,
+			},
+		})
+
+		list, err := ftpDownloader.Browse(parsedDest)
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		fileFound := false
+		for _, remoteFile := range list {
+			if fileFound = remoteFile.Name == fileName; fileFound {
+				break
+			}
+		}
+		assertions.True(fileFound, "File must be found")
+	})
+
+	//FtpDownload_ReturnsCorrectRemoteFile_TLSExplicit - old name
+	ftpDownloader = FtpDownloader{}
+	t.Run("FtpDownloadReturnsCorrectRemoteFileTLSExplicit", func(t *testing.T) {
+		expectedResult := fileData
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{
+			Url: "ftp://" + ServerIP + "/" + fileName,
+			Credentials: &models.Credentials{
+				User:     "test",
+				Password: "test",
+				TLSConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+				TLSMode: models.TLSExplicit,
+			},
+		})
+
+		result, err := ftpDownloader.Download(remoteFile)
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		assertions.Equal(fileName, result.Name, fmt.Sprintf("Received file name %v, expected - %v", result.Name, fileName))
+
+		blobBytes, err := ioutil.ReadAll(result.Blob)
+		assertions.Equal(expectedResult, string(blobBytes))
+
+		err = result.Blob.Close()
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+
+	})
+
+	t.Run("FtpsDownloadReturnsCorrectRemoteFileTLSExplicit", func(t *testing.T) {
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{
+			Url: "ftps://" + ServerIP + "/" + fileName,
+			Credentials: &models.Credentials{
+				User:       "test",
+				Password:   "test",
+				TLSOptions: &models.TLSOptions{InsecureSkipVerify: true},
+				TLSMode:    models.TLSExplicit,
+			},
+		})
+
+		result, err := ftpDownloader.Download(remoteFile)
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		blobBytes, err := ioutil.ReadAll(result.Blob)
+		assertions.Equal(fileData, string(blobBytes))
+		assertions.NoError(result.Blob.Close())
+	})
+
+	t.Run("FtpDownloadReusesTLSSessionTLSExplicit", func(t *testing.T) {
+		sessionCache := &countingSessionCache{ClientSessionCache: tls.NewLRUClientSessionCache(0)}
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{
+			Url: "ftp://" + ServerIP + "/" + fileName,
+			Credentials: &models.Credentials{
+				User:      "test",
+				Password:  "test",
+				TLSConfig: &tls.Config{InsecureSkipVerify: true, ClientSessionCache: sessionCache},
+				TLSMode:   models.TLSExplicit,
+			},
+			Ftp: &models.FtpOptions{TLSSessionReuse: true},
+		})
+
+		result, err := ftpDownloader.Download(remoteFile)
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		assertions.True(sessionCache.hits > 0, "Data connection must resume session of control connection")
+		blobBytes, err := ioutil.ReadAll(result.Blob)
+		assertions.Equal(fileData, string(blobBytes))
+		assertions.NoError(result.Blob.Close())
+	})
+
+	t.Run("FtpDownloadClearDataConnectionTLSExplicit", func(t *testing.T) {
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{
+			Url: "ftp://" + ServerIP + "/" + fileName,
+			Credentials: &models.Credentials{
+				User:      "test",
+				Password:  "test",
+				TLSConfig: &tls.Config{InsecureSkipVerify: true},
+				TLSMode:   models.TLSExplicit,
+			},
+			Ftp: &models.FtpOptions{DataProtection: models.FtpClearProtection},
+		})
+
+		result, err := ftpDownloader.Download(remoteFile)
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		blobBytes, err := ioutil.ReadAll(result.Blob)
+		assertions.Equal(fileData, string(blobBytes))
+		assertions.NoError(result.Blob.Close())
+	})
+
+	t.Run("FtpBrowseClearDataConnectionTLSExplicit", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{
+			Url: "ftp://" + ServerIP + "/",
+			Credentials: &models.Credentials{
+				User:      "test",
+				Password:  "test",
+				TLSConfig: &tls.Config{InsecureSkipVerify: true},
+				TLSMode:   models.TLSExplicit,
+			},
+			Ftp: &models.FtpOptions{DataProtection: models.FtpClearProtection, TLSSessionReuse: true},
+		})
+
+		list, err := ftpDownloader.Browse(parsedDest)
+
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.NotEqual(len(list), 0, "Files not found")
+	})
 
+	//error tests
+	t.Run("FtpCCCNotSupportedReturnsErrorTLSExplicit", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{
+			Url: "ftp://" + ServerIP + "/",
+			Credentials: &models.Credentials{
+				User:      "test",
+				Password:  "test",
+				TLSConfig: &tls.Config{InsecureSkipVerify: true},
+				TLSMode:   models.TLSExplicit,
+			},
+			Ftp: &models.FtpOptions{ClearControlChannel: true},
+		})
+
+		list, err := ftpDownloader.Browse(parsedDest)
+
+		assertions.NotNil(err)
+		assertions.Contains(err.Error(), "CCC")
+		assertions.Len(list, 0)
+	})
+
+	t.Run("FtpCCCWithoutTLSReturnsError", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{
+			Url:         "ftp://" + ServerIP + "/",
+			Credentials: &models.Credentials{User: "test", Password: "test"},
+			Ftp:         &models.FtpOptions{ClearControlChannel: true},
+		})
+
+		_, err := ftpDownloader.Browse(parsedDest)
+
+		assertions.NotNil(err)
+		assertions.Contains(err.Error(), "without TLS")
+	})
+
+	t.Run("FtpUnknownDataProtectionReturnsError", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{
+			Url:         "ftp://" + ServerIP + "/",
+			Credentials: &models.Credentials{User: "test", Password: "test"},
+			Ftp:         &models.FtpOptions{DataProtection: "S"},
+		})
+
+		_, err := ftpDownloader.Browse(parsedDest)
+
+		assertions.NotNil(err)
+		assertions.Contains(err.Error(), "unknown ftp data protection level")
+	})
 	//TLSExplicit/////////////////////////////////////////////////////////////////\
 
 	t.Run("FtpRemoveFileNoError", func(t *testing.T) {
@@ -381,3 +509,960 @@ func Test_Locale_TLS(t *testing.T) {
 		assertions.Nil(err)
 	})
 }
+
+func Test_FtpDownloader_Stat(t *testing.T) {
+	assertions := assertLib.New(t)
+	fileData := "hello world"
+	statDir := path.Join(ftpServerDriver.TempDir, "stat", "nested")
+	if err := CreateDirIfNotExist(statDir); err != nil {
+		t.Fatal("Couldn't create temp dir: "+statDir, err)
+	}
+	defer os.RemoveAll(path.Join(ftpServerDriver.TempDir, "stat"))
+	if err := ioutil.WriteFile(path.Join(ftpServerDriver.TempDir, "stat", "file.txt"), []byte(fileData), 0644); err != nil {
+		t.Fatal("Couldn't write to file:", err)
+	}
+	lastmod := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := os.Chtimes(path.Join(ftpServerDriver.TempDir, "stat", "file.txt"), lastmod, lastmod); err != nil {
+		t.Fatal("Couldn't set file time:", err)
+	}
+
+	ftpDownloader := &FtpDownloader{}
+	for _, disableMLST := range []bool{false, true} {
+		s := ftpServerDriver.NewTestServerWithDriver(&ftpServerDriver.ServerDriver{
+			Settings: &server.Settings{ListenAddr: "127.0.0.1:0", DisableMLST: disableMLST},
+		})
+		destination := func(filePath string) *models.ParsedDestination {
+			parsedDest, _ := models.ParseDestination(&models.Destination{
+				Url:         "ftp://" + s.Addr() + filePath,
+				Credentials: &models.Credentials{User: "test", Password: "test"},
+			})
+			return parsedDest
+		}
+		name := "MLST"
+		if disableMLST {
+			name = "SizeMdtm"
+		}
+
+		t.Run("FtpStatFile_ReturnsFileInfo_"+name, func(t *testing.T) {
+			remoteFile, err := ftpDownloader.Stat(destination("/stat/file.txt"))
+
+			if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+				return
+			}
+			assertions.Equal("file.txt", remoteFile.Name)
+			assertions.Equal("/stat/", remoteFile.Path)
+			assertions.Equal(int64(len(fileData)), remoteFile.Size)
+			assertions.False(remoteFile.IsDir)
+			//test server writes MLST modify fact in local time instead of UTC
+			_, offset := lastmod.In(time.Local).Zone()
+			assertions.True(remoteFile.Lastmod.Equal(lastmod) || remoteFile.Lastmod.Equal(lastmod.Add(time.Duration(offset)*time.Second)),
+				fmt.Sprintf("Lastmod %v, expected %v", remoteFile.Lastmod, lastmod))
+		})
+		t.Run("FtpStatDir_ReturnsDirInfo_"+name, func(t *testing.T) {
+			remoteFile, err := ftpDownloader.Stat(destination("/stat/nested/"))
+
+			if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+				return
+			}
+			assertions.Equal("nested", remoteFile.Name)
+			assertions.Equal("/stat/", remoteFile.Path)
+			assertions.True(remoteFile.IsDir)
+		})
+
+		//error tests
+		t.Run("FtpStatMissingFile_ReturnsNotFoundError_"+name, func(t *testing.T) {
+			remoteFile, err := ftpDownloader.Stat(destination("/stat/missing.txt"))
+
+			assertions.Nil(remoteFile)
+			assertions.True(errors.Is(err, models.ErrNotFound), fmt.Sprintf("err == %v, expected - not found", err))
+		})
+		s.Stop()
+	}
+
+	scriptedDestination, _ := models.ParseDestination(&models.Destination{Url: "ftp://127.0.0.1/files/test.txt"})
+	t.Run("FtpStatFile_SendsSizeInBinaryMode", func(t *testing.T) {
+		conn := &fakeRawConn{replies: map[string]string{
+			"CWD /files/test.txt":  "550 Not a directory",
+			"TYPE I":               "200 Type set to I",
+			"SIZE /files/test.txt": "213 11",
+		}}
+		session := &ftpSession{conn: conn}
+
+		remoteFile, err := session.stat(scriptedDestination)
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		assertions.Equal(int64(11), remoteFile.Size)
+		assertions.Equal([]string{"MLST /files/test.txt", "CWD /files/test.txt", "TYPE I", "SIZE /files/test.txt", "MDTM /files/test.txt"}, conn.commands)
+	})
+
+	//error tests
+	t.Run("FtpStatSizeNotAllowedInAsciiMode_ReturnsError", func(t *testing.T) {
+		session := &ftpSession{conn: &fakeRawConn{replies: map[string]string{
+			"CWD /files/test.txt":  "550 Not a directory",
+			"TYPE I":               "504 Type not supported",
+			"SIZE /files/test.txt": "550 SIZE not allowed in ASCII mode",
+		}}}
+
+		_, err := session.stat(scriptedDestination)
+
+		assertions.NotNil(err)
+		assertions.False(errors.Is(err, models.ErrNotFound), fmt.Sprintf("err == %v, expected - not not found", err))
+	})
+	t.Run("FtpStatMissingFileInBinaryMode_ReturnsNotFoundError", func(t *testing.T) {
+		session := &ftpSession{conn: &fakeRawConn{replies: map[string]string{
+			"CWD /files/test.txt":  "550 No such file",
+			"TYPE I":               "200 Type set to I",
+			"SIZE /files/test.txt": "550 No such file",
+		}}}
+
+		_, err := session.stat(scriptedDestination)
+
+		assertions.True(errors.Is(err, models.ErrNotFound), fmt.Sprintf("err == %v, expected - not found", err))
+	})
+}
+
+func Test_FtpDownloader_ParseMlstFacts(t *testing.T) {
+	assertions := assertLib.New(t)
+
+	remoteFile := &models.RemoteFile{}
+	err := parseMlstFacts(" File details\n type=file;size=1024;modify=20200304050607.123;UNIX.mode=0644; /dir/a; b.txt\nEnd", remoteFile)
+	assertions.NoError(err)
+	assertions.Equal(int64(1024), remoteFile.Size)
+	assertions.Equal(time.Date(2020, 3, 4, 5, 6, 7, 123000000, time.UTC), remoteFile.Lastmod)
+	assertions.False(remoteFile.IsDir)
+
+	remoteFile = &models.RemoteFile{}
+	err = parseMlstFacts("Type=cdir;Modify=20200304050607; /dir", remoteFile)
+	assertions.NoError(err)
+	assertions.True(remoteFile.IsDir)
+	assertions.Equal(int64(0), remoteFile.Size)
+
+	//error tests
+	assertions.Error(parseMlstFacts("End of details", &models.RemoteFile{}))
+	assertions.Error(parseMlstFacts("type=file;size=big; a.txt", &models.RemoteFile{}))
+}
+
+func Test_FtpDownloader_DataConnection(t *testing.T) {
+	assertions := assertLib.New(t)
+	fileData := "hello world"
+	dataDir := path.Join(ftpServerDriver.TempDir, "dataconn")
+	if err := CreateDirIfNotExist(dataDir); err != nil {
+		t.Fatal("Couldn't create temp dir: "+dataDir, err)
+	}
+	defer os.RemoveAll(dataDir)
+	if err := ioutil.WriteFile(path.Join(dataDir, "file.txt"), []byte(fileData), 0644); err != nil {
+		t.Fatal("Couldn't write to file:", err)
+	}
+
+	//server behind NAT advertises address, which is not reachable by client
+	s := ftpServerDriver.NewTestServerWithDriver(&ftpServerDriver.ServerDriver{
+		Settings: &server.Settings{ListenAddr: "127.0.0.1:0", PublicHost: "192.0.2.1", NonStandardActiveDataPort: true},
+	})
+	defer s.Stop()
+	destination := func(filePath string, options *models.FtpOptions) *models.Destination {
+		return &models.Destination{
+			Url:         "ftp://" + s.Addr() + filePath,
+			Credentials: &models.Credentials{User: "test", Password: "test"},
+			Timeout:     time.Second,
+			Ftp:         options,
+		}
+	}
+
+	ftpDownloader := &FtpDownloader{}
+	for name, options := range map[string]*models.FtpOptions{
+		"Default":       nil,
+		"Active":        {DataConnectionMode: models.FtpActiveMode},
+		"ActiveListen":  {DataConnectionMode: models.FtpActiveMode, ActiveListenAddr: "127.0.0.1:0"},
+		"EPSV":          {DataConnectionMode: models.FtpEPSVMode},
+		"PASVControlIP": {DataConnectionMode: models.FtpPASVMode, UseControlIPForPASV: true},
+	} {
+		t.Run("FtpBrowse_ReturnsList_"+name, func(t *testing.T) {
+			parsedDest, _ := models.ParseDestination(destination("/dataconn/", options))
+			list, err := ftpDownloader.Browse(parsedDest)
+
+			if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+				return
+			}
+			if assertions.Len(list, 1) {
+				assertions.Equal("file.txt", list[0].Name)
+				assertions.Equal(int64(len(fileData)), list[0].Size)
+			}
+		})
+		t.Run("FtpDownload_ReturnsContent_"+name, func(t *testing.T) {
+			remoteFile, _ := models.NewRemoteFile(destination("/dataconn/file.txt", options))
+			result, err := ftpDownloader.Download(remoteFile)
+
+			if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+				return
+			}
+			blobBytes, err := ioutil.ReadAll(result.Blob)
+			assertions.NoError(err)
+			assertions.Equal(fileData, string(blobBytes))
+			assertions.NoError(result.Blob.Close())
+		})
+	}
+
+	t.Run("FtpBrowse_ReturnsListWithoutMLSD", func(t *testing.T) {
+		listServer := ftpServerDriver.NewTestServerWithDriver(&ftpServerDriver.ServerDriver{
+			Settings: &server.Settings{ListenAddr: "127.0.0.1:0", DisableMLSD: true},
+		})
+		defer listServer.Stop()
+		parsedDest, _ := models.ParseDestination(&models.Destination{
+			Url:         "ftp://" + listServer.Addr() + "/dataconn/",
+			Credentials: &models.Credentials{User: "test", Password: "test"},
+			Timeout:     time.Second,
+		})
+		list, err := ftpDownloader.Browse(parsedDest)
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		if assertions.Len(list, 1) {
+			assertions.Equal("file.txt", list[0].Name)
+			assertions.Equal(int64(len(fileData)), list[0].Size)
+		}
+	})
+
+	t.Run("FtpBrowse_CustomParser_ReportsListingWarnings", func(t *testing.T) {
+		if err := ioutil.WriteFile(path.Join(dataDir, "other.txt"), []byte(fileData), 0644); err != nil {
+			t.Fatal("Couldn't write to file:", err)
+		}
+		listServer := ftpServerDriver.NewTestServerWithDriver(&ftpServerDriver.ServerDriver{
+			Settings: &server.Settings{ListenAddr: "127.0.0.1:0", DisableMLSD: true},
+		})
+		defer listServer.Stop()
+		parsedDest, _ := models.ParseDestination(&models.Destination{
+			Url:         "ftp://" + listServer.Addr() + "/dataconn/",
+			Credentials: &models.Credentials{User: "test", Password: "test"},
+			Timeout:     time.Second,
+		})
+		var warnings []ListingWarning
+		customDownloader := &FtpDownloader{ListingParsers: []ListingParser{&fileTxtListingParser{}},
+			OnListingWarnings: func(url string, lines []ListingWarning) {
+				assertions.Equal(parsedDest.Url, url)
+				warnings = append(warnings, lines...)
+			}}
+		list, err := customDownloader.Browse(parsedDest)
+
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		if assertions.Len(list, 1) {
+			assertions.Equal("custom.txt", list[0].Name)
+			assertions.Equal("/dataconn/", list[0].Path)
+		}
+		if assertions.Len(warnings, 1) {
+			assertions.True(strings.HasSuffix(warnings[0].Line, " other.txt"))
+		}
+	})
+
+	//error tests
+	t.Run("FtpDownload_PASVToAdvertisedAddress_ReturnsError", func(t *testing.T) {
+		remoteFile, _ := models.NewRemoteFile(destination("/dataconn/file.txt", &models.FtpOptions{DataConnectionMode: models.FtpPASVMode}))
+		result, err := ftpDownloader.Download(remoteFile)
+
+		assertions.Nil(result)
+		assertions.Error(err)
+	})
+	t.Run("FtpBrowse_MissingDir_ReturnsNotFoundError", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(destination("/dataconn/missing/", nil))
+		list, err := ftpDownloader.Browse(parsedDest)
+
+		assertions.Nil(list)
+		assertions.True(errors.Is(err, models.ErrNotFound), fmt.Sprintf("err == %v, expected - not found", err))
+	})
+	t.Run("FtpBrowse_UnknownMode_ReturnsError", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(destination("/dataconn/", &models.FtpOptions{DataConnectionMode: "extended"}))
+		list, err := ftpDownloader.Browse(parsedDest)
+
+		assertions.Nil(list)
+		assertions.Error(err)
+	})
+}
+
+func Test_FtpDownloader_ListingParsers(t *testing.T) {
+	assertions := assertLib.New(t)
+	now := time.Date(2021, 2, 10, 12, 0, 0, 0, time.UTC)
+	unixParser := &UnixListingParser{Now: func() time.Time { return now }}
+
+	t.Run("Unix", func(t *testing.T) {
+		line := "-rw-r--r--   1 ftp      ftp          1024 Mar  4 05:06 my file.txt"
+		assertions.True(unixParser.CanParse(line))
+		remoteFile, err := unixParser.Parse(line)
+		if assertions.NoError(err) {
+			assertions.Equal("my file.txt", remoteFile.Name)
+			assertions.Equal(int64(1024), remoteFile.Size)
+			assertions.Equal(time.Date(2020, 3, 4, 5, 6, 0, 0, time.UTC), remoteFile.Lastmod)
+			assertions.False(remoteFile.IsDir)
+			assertions.Equal(os.FileMode(0644), *remoteFile.Metadata.Mode)
+			assertions.Equal("ftp", remoteFile.Metadata.Owner)
+			assertions.Equal("ftp", remoteFile.Metadata.Group)
+		}
+
+		remoteFile, err = unixParser.Parse("drwxr-xr-x 2 owner 0 Jan 15 2019 dir")
+		if assertions.NoError(err) {
+			assertions.Equal("dir", remoteFile.Name)
+			assertions.Equal(time.Date(2019, 1, 15, 0, 0, 0, 0, time.UTC), remoteFile.Lastmod)
+			assertions.True(remoteFile.IsDir)
+			assertions.Equal(os.ModeDir|0755, *remoteFile.Metadata.Mode)
+			assertions.Equal("owner", remoteFile.Metadata.Owner)
+			assertions.Equal("", remoteFile.Metadata.Group)
+		}
+
+		remoteFile, err = unixParser.Parse("drwxrwsr-t 2 owner staff 0 Jan 15 2019 shared")
+		if assertions.NoError(err) {
+			assertions.Equal(os.ModeDir|os.ModeSetgid|os.ModeSticky|0775, *remoteFile.Metadata.Mode)
+		}
+
+		remoteFile, err = unixParser.Parse("lrwxrwxrwx 1 ftp ftp 8 Feb  1 10:00 link -> file.txt")
+		if assertions.NoError(err) {
+			assertions.Equal("link", remoteFile.Name)
+		}
+
+		remoteFile, err = unixParser.Parse("total 12")
+		assertions.NoError(err)
+		assertions.Nil(remoteFile)
+	})
+	t.Run("Dos", func(t *testing.T) {
+		parser := &DosListingParser{}
+		line := "02-01-21  10:00PM       <DIR>          my dir"
+		assertions.True(parser.CanParse(line))
+		remoteFile, err := parser.Parse(line)
+		if assertions.NoError(err) {
+			assertions.Equal("my dir", remoteFile.Name)
+			assertions.Equal(time.Date(2021, 2, 1, 22, 0, 0, 0, time.UTC), remoteFile.Lastmod)
+			assertions.True(remoteFile.IsDir)
+		}
+
+		remoteFile, err = parser.Parse("12-31-2020  09:15         1,048,576 report.csv")
+		if assertions.NoError(err) {
+			assertions.Equal("report.csv", remoteFile.Name)
+			assertions.Equal(int64(1048576), remoteFile.Size)
+			assertions.Equal(time.Date(2020, 12, 31, 9, 15, 0, 0, time.UTC), remoteFile.Lastmod)
+		}
+	})
+	t.Run("Eplf", func(t *testing.T) {
+		parser := &EplfListingParser{}
+		line := "+i8388621.48594,m825718503,r,s280,\tdjb.html"
+		assertions.True(parser.CanParse(line))
+		remoteFile, err := parser.Parse(line)
+		if assertions.NoError(err) {
+			assertions.Equal("djb.html", remoteFile.Name)
+			assertions.Equal(int64(280), remoteFile.Size)
+			assertions.Equal(time.Unix(825718503, 0).UTC(), remoteFile.Lastmod)
+			assertions.False(remoteFile.IsDir)
+		}
+
+		remoteFile, err = parser.Parse("+i8388621.50690,m824255907,/,\t514")
+		if assertions.NoError(err) {
+			assertions.Equal("514", remoteFile.Name)
+			assertions.True(remoteFile.IsDir)
+		}
+	})
+	t.Run("Mlsd", func(t *testing.T) {
+		parser := &MlsdListingParser{}
+		line := "Type=file;Size=11;Modify=20200304050607; a b.txt"
+		assertions.True(parser.CanParse(line))
+		remoteFile, err := parser.Parse(line)
+		if assertions.NoError(err) {
+			assertions.Equal("a b.txt", remoteFile.Name)
+			assertions.Equal(int64(11), remoteFile.Size)
+			assertions.Nil(remoteFile.Metadata.Mode)
+			assertions.Equal("file", remoteFile.Metadata.Facts["type"])
+		}
+
+		remoteFile, err = parser.Parse("type=dir;modify=20200304050607;UNIX.mode=02775;UNIX.uid=1000;UNIX.gid=50;UNIX.owner=ftp;" +
+			"UNIX.group=staff;unique=801U1; shared")
+		if assertions.NoError(err) {
+			assertions.Equal(os.ModeDir|os.ModeSetgid|0775, *remoteFile.Metadata.Mode)
+			assertions.Equal(uint32(1000), *remoteFile.Metadata.Uid)
+			assertions.Equal(uint32(50), *remoteFile.Metadata.Gid)
+			assertions.Equal("ftp", remoteFile.Metadata.Owner)
+			assertions.Equal("staff", remoteFile.Metadata.Group)
+			assertions.Equal("801U1", remoteFile.Metadata.Facts["unique"])
+		}
+
+		remoteFile, err = parser.Parse("type=cdir;modify=20200304050607; /dir")
+		assertions.NoError(err)
+		assertions.Nil(remoteFile)
+	})
+	t.Run("AutoDetection_SkipsUnparsedLines", func(t *testing.T) {
+		destination, _ := models.ParseDestination(&models.Destination{Url: "ftp://ftp.com/dir/"})
+		list, warnings := parseListing(destination, []string{
+			"total 2",
+			"-rw-r--r-- 1 ftp ftp 11 Jan 15 2019 unix.txt",
+			"02-01-21  10:00AM                   12 dos.txt",
+			"+r,s13,m825718503,\teplf.txt",
+			"[VMS] FILE.TXT;1 2/4 1-JAN-2021 10:00",
+		}, defaultListingParsers)
+
+		if assertions.Len(list, 3) {
+			assertions.Equal("unix.txt", list[0].Name)
+			assertions.Equal(int64(12), list[1].Size)
+			assertions.Equal("eplf.txt", list[2].Name)
+			assertions.Equal("/dir/", list[2].Path)
+		}
+		if assertions.Len(warnings, 1) {
+			assertions.Equal("[VMS] FILE.TXT;1 2/4 1-JAN-2021 10:00", warnings[0].Line)
+		}
+	})
+
+	ip, port, err := parsePasvReply("Entering Passive Mode (192,168,1,2,195,149).")
+	assertions.NoError(err)
+	assertions.Equal("192.168.1.2", ip)
+	assertions.Equal(195*256+149, port)
+
+	port, err = parseEpsvReply("Entering Extended Passive Mode (|||6446|)")
+	assertions.NoError(err)
+	assertions.Equal(6446, port)
+
+	//error tests
+	assertions.False(unixParser.CanParse("02-01-21  10:00AM       <DIR>          dir"))
+	_, err = unixParser.Parse("-rw-r--r-- 1 ftp ftp size Jan 15 2019 file.txt")
+	assertions.Error(err)
+	_, err = (&DosListingParser{}).Parse("13-45-21  10:00AM  12 file.txt")
+	assertions.Error(err)
+	_, err = (&EplfListingParser{}).Parse("+s12x,\tfile.txt")
+	assertions.Error(err)
+	_, _, err = parsePasvReply("Entering Passive Mode (192,168,1,2,195)")
+	assertions.Error(err)
+	_, err = parseEpsvReply("Entering Extended Passive Mode (|||port|)")
+	assertions.Error(err)
+}
+
+//Parses lines of test server LIST, which end with "file.txt", and fails on others
+type fileTxtListingParser struct{}
+
+func (parser *fileTxtListingParser) CanParse(line string) bool {
+	return true
+}
+
+func (parser *fileTxtListingParser) Parse(line string) (*models.RemoteFile, error) {
+	if !strings.HasSuffix(line, " file.txt") {
+		return nil, errors.New("not a file.txt")
+	}
+	return &models.RemoteFile{Name: "custom.txt"}, nil
+}
+
+func Test_FtpDownloader_Encoding(t *testing.T) {
+	assertions := assertLib.New(t)
+	fileData := "line 1\r\nline 2\r\n"
+	name := "отчёт 1.txt"
+	//file names are stored on legacy server in cp1251
+	encodedName, _ := charmap.Windows1251.NewEncoder().String(name)
+	encodingDir := path.Join(ftpServerDriver.TempDir, "encoding")
+	if err := CreateDirIfNotExist(encodingDir); err != nil {
+		t.Fatal("Couldn't create temp dir: "+encodingDir, err)
+	}
+	defer os.RemoveAll(encodingDir)
+	for _, fileName := range []string{encodedName, "remove-" + encodedName} {
+		if err := ioutil.WriteFile(path.Join(encodingDir, fileName), []byte(fileData), 0644); err != nil {
+			t.Fatal("Couldn't write to file:", err)
+		}
+	}
+
+	s := ftpServerDriver.NewTestServerWithDriver(&ftpServerDriver.ServerDriver{
+		Settings: &server.Settings{ListenAddr: "127.0.0.1:0", DisableMLSD: true, DisableMLST: true},
+	})
+	defer s.Stop()
+	destination := func(filePath string, options *models.FtpOptions) *models.Destination {
+		return &models.Destination{
+			Url:         "ftp://" + s.Addr() + filePath,
+			Credentials: &models.Credentials{User: "test", Password: "test"},
+			Timeout:     time.Second,
+			Ftp:         options,
+		}
+	}
+	cp1251 := &models.FtpOptions{Encoding: models.FtpCP1251}
+
+	ftpDownloader := &FtpDownloader{}
+	t.Run("FtpBrowse_ReturnsDecodedNames", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(destination("/encoding/", cp1251))
+		list, err := ftpDownloader.Browse(parsedDest)
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		names := make([]string, 0)
+		for _, remoteFile := range list {
+			names = append(names, remoteFile.Name)
+		}
+		assertions.ElementsMatch([]string{name, "remove-" + name}, names)
+	})
+	t.Run("FtpStat_ReturnsFileInfo", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(destination("/encoding/"+name, cp1251))
+		remoteFile, err := ftpDownloader.Stat(parsedDest)
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		assertions.Equal(name, remoteFile.Name)
+		assertions.Equal(int64(len(fileData)), remoteFile.Size)
+	})
+	for _, transferType := range []models.FtpTransferType{models.FtpBinaryType, models.FtpASCIIType} {
+		t.Run("FtpDownload_ReturnsContent_"+string(transferType), func(t *testing.T) {
+			remoteFile, _ := models.NewRemoteFile(destination("/encoding/"+name, &models.FtpOptions{Encoding: models.FtpCP1251, TransferType: transferType}))
+			result, err := ftpDownloader.Download(remoteFile)
+
+			if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+				return
+			}
+			assertions.Equal(name, result.Name)
+			blobBytes, err := ioutil.ReadAll(result.Blob)
+			assertions.NoError(err)
+			if transferType == models.FtpASCIIType {
+				assertions.Equal("line 1\nline 2\n", string(blobBytes))
+			} else {
+				assertions.Equal(fileData, string(blobBytes))
+			}
+			assertions.NoError(result.Blob.Close())
+		})
+	}
+	t.Run("FtpRemove_RemovesFile", func(t *testing.T) {
+		remoteFile, _ := models.NewRemoteFile(destination("/encoding/remove-"+name, cp1251))
+		err := ftpDownloader.Remove(remoteFile)
+
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		_, err = os.Stat(path.Join(encodingDir, "remove-"+encodedName))
+		assertions.True(os.IsNotExist(err))
+	})
+	t.Run("FtpBrowse_NegotiateUTF8", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(destination("/encoding/", &models.FtpOptions{NegotiateUTF8: true}))
+		list, err := ftpDownloader.Browse(parsedDest)
+
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Len(list, 1)
+	})
+
+	//error tests
+	t.Run("FtpDownload_NameNotInEncoding_ReturnsError", func(t *testing.T) {
+		remoteFile, _ := models.NewRemoteFile(destination("/encoding/文件.txt", cp1251))
+		result, err := ftpDownloader.Download(remoteFile)
+
+		assertions.Nil(result)
+		assertions.Error(err)
+	})
+	t.Run("FtpBrowse_InvalidOptions_ReturnsError", func(t *testing.T) {
+		for _, options := range []*models.FtpOptions{
+			{Encoding: "utf-16"},
+			{TransferType: "E"},
+			{Encoding: models.FtpKOI8R, NegotiateUTF8: true},
+		} {
+			parsedDest, _ := models.ParseDestination(destination("/encoding/", options))
+			list, err := ftpDownloader.Browse(parsedDest)
+
+			assertions.Nil(list)
+			assertions.Error(err)
+		}
+	})
+}
+
+func Test_FtpDownloader_CrlfWriter(t *testing.T) {
+	assertions := assertLib.New(t)
+
+	var buf strings.Builder
+	writer := &crlfWriter{writer: &buf}
+	//CRLF is split between chunks, lone CRs are kept
+	for _, chunk := range []string{"a\r", "\nb\rc\r\n", "\r"} {
+		n, err := writer.Write([]byte(chunk))
+		assertions.NoError(err)
+		assertions.Equal(len(chunk), n)
+	}
+	assertions.NoError(writer.Flush())
+	assertions.Equal("a\nb\rc\n\r", buf.String())
+}
+
+func Test_FtpDownloader_RemoveRecursive(t *testing.T) {
+	assertions := assertLib.New(t)
+	treeDir := path.Join(ftpServerDriver.TempDir, "tree")
+	keepDir := path.Join(ftpServerDriver.TempDir, "tree_link_target")
+	if err := CreateDirIfNotExist(path.Join(treeDir, "sub", "deeper")); err != nil {
+		t.Fatal("Couldn't create temp dir: "+treeDir, err)
+	}
+	if err := CreateDirIfNotExist(keepDir); err != nil {
+		t.Fatal("Couldn't create temp dir: "+keepDir, err)
+	}
+	defer os.RemoveAll(treeDir)
+	defer os.RemoveAll(keepDir)
+	for _, filePath := range []string{"tree/a.txt", "tree/sub/b.txt", "tree/sub/deeper/c.txt", "tree_link_target/keep.txt"} {
+		if err := ioutil.WriteFile(path.Join(ftpServerDriver.TempDir, filePath), []byte("hello world"), 0644); err != nil {
+			t.Fatal("Couldn't write to file:", err)
+		}
+	}
+	if err := os.Symlink(keepDir, path.Join(treeDir, "link")); err != nil {
+		t.Fatal("Couldn't create symlink:", err)
+	}
+
+	s := ftpServerDriver.NewTestServerWithDriver(&ftpServerDriver.ServerDriver{
+		Settings: &server.Settings{ListenAddr: "127.0.0.1:0"},
+	})
+	defer s.Stop()
+	remoteFile := func(filePath string) *models.RemoteFile {
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{
+			Url:         "ftp://" + s.Addr() + filePath,
+			Credentials: &models.Credentials{User: "test", Password: "test"},
+			Timeout:     time.Second,
+		})
+		return remoteFile
+	}
+
+	ftpDownloader := &FtpDownloader{}
+	t.Run("FtpRemoveRecursiveDryRun_ReturnsPathsAndRemovesNothing", func(t *testing.T) {
+		removed, err := ftpDownloader.RemoveRecursive(remoteFile("/tree"), &models.RemoveOptions{DryRun: true})
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		assertions.ElementsMatch([]string{"/tree/a.txt", "/tree/link", "/tree/sub/b.txt", "/tree/sub/deeper/c.txt",
+			"/tree/sub/deeper", "/tree/sub", "/tree"}, removed)
+		//files and subdirectories go before their directory
+		index := make(map[string]int)
+		for i, removedPath := range removed {
+			index[removedPath] = i
+		}
+		assertions.True(index["/tree/sub/deeper/c.txt"] < index["/tree/sub/deeper"])
+		assertions.True(index["/tree/sub/deeper"] < index["/tree/sub"])
+		assertions.Equal(len(removed)-1, index["/tree"])
+		_, err = os.Stat(path.Join(treeDir, "sub", "deeper", "c.txt"))
+		assertions.NoError(err, "dry run must not remove files")
+	})
+	t.Run("FtpRemoveRecursive_RemovesTreeAndKeepsLinkTarget", func(t *testing.T) {
+		removed, err := ftpDownloader.RemoveRecursive(remoteFile("/tree"), nil)
+
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) {
+			return
+		}
+		assertions.Len(removed, 7)
+		_, err = os.Stat(treeDir)
+		assertions.True(os.IsNotExist(err), "tree must be removed")
+		_, err = os.Stat(path.Join(keepDir, "keep.txt"))
+		assertions.NoError(err, "symlink target must be kept")
+	})
+	t.Run("FtpRemoveRecursiveBrowsedEntries_RemovesFileAndDir", func(t *testing.T) {
+		browseDir := path.Join(ftpServerDriver.TempDir, "rv")
+		if err := CreateDirIfNotExist(path.Join(browseDir, "sub")); err != nil {
+			t.Fatal("Couldn't create temp dir: "+browseDir, err)
+		}
+		defer os.RemoveAll(browseDir)
+		for _, filePath := range []string{"rv/a.txt", "rv/sub/b.txt"} {
+			if err := ioutil.WriteFile(path.Join(ftpServerDriver.TempDir, filePath), []byte("hello world"), 0644); err != nil {
+				t.Fatal("Couldn't write to file:", err)
+			}
+		}
+		list, err := ftpDownloader.Browse(remoteFile("/rv/").ParsedDestination)
+		if !assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err)) || !assertions.Len(list, 2) {
+			return
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+		removed, err := ftpDownloader.RemoveRecursive(list[0], &models.RemoveOptions{DryRun: true})
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal([]string{"/rv/a.txt"}, removed)
+
+		removed, err = ftpDownloader.RemoveRecursive(list[1], nil)
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal([]string{"/rv/sub/b.txt", "/rv/sub"}, removed)
+		_, err = os.Stat(path.Join(browseDir, "sub"))
+		assertions.True(os.IsNotExist(err), "browsed directory must be removed")
+		_, err = os.Stat(path.Join(browseDir, "a.txt"))
+		assertions.NoError(err, "dry run must not remove files")
+	})
+	t.Run("FtpRemoveRecursiveFile_RemovesFile", func(t *testing.T) {
+		removed, err := ftpDownloader.RemoveRecursive(remoteFile("/tree_link_target/keep.txt"), nil)
+
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal([]string{"/tree_link_target/keep.txt"}, removed)
+	})
+
+	t.Run("FtpRemoveRecursiveUnparsedLine_RemovesParsedEntries", func(t *testing.T) {
+		partialDir := path.Join(ftpServerDriver.TempDir, "partial")
+		if err := CreateDirIfNotExist(partialDir); err != nil {
+			t.Fatal("Couldn't create temp dir: "+partialDir, err)
+		}
+		defer os.RemoveAll(partialDir)
+		for _, name := range []string{"a.txt", "skip.txt"} {
+			if err := ioutil.WriteFile(path.Join(partialDir, name), []byte("hello world"), 0644); err != nil {
+				t.Fatal("Couldn't write to file:", err)
+			}
+		}
+		listServer := ftpServerDriver.NewTestServerWithDriver(&ftpServerDriver.ServerDriver{
+			Settings: &server.Settings{ListenAddr: "127.0.0.1:0", DisableMLSD: true},
+		})
+		defer listServer.Stop()
+		partialFile, _ := models.NewRemoteFile(&models.Destination{
+			Url:         "ftp://" + listServer.Addr() + "/partial",
+			Credentials: &models.Credentials{User: "test", Password: "test"},
+			Timeout:     time.Second,
+		})
+		var warnings []ListingWarning
+		partialDownloader := &FtpDownloader{ListingParsers: []ListingParser{&skipTxtListingParser{}},
+			OnListingWarnings: func(url string, lines []ListingWarning) {
+				warnings = append(warnings, lines...)
+			}}
+
+		removed, err := partialDownloader.RemoveRecursive(partialFile, &models.RemoveOptions{DryRun: true})
+
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal([]string{"/partial/a.txt", "/partial"}, removed)
+		if assertions.Len(warnings, 1) {
+			assertions.True(strings.HasSuffix(warnings[0].Line, " skip.txt"))
+		}
+
+		//directory with unparsed entry is not empty
+		removed, err = partialDownloader.RemoveRecursive(partialFile, nil)
+
+		assertions.NotNil(err)
+		assertions.Equal([]string{"/partial/a.txt"}, removed)
+		_, err = os.Stat(path.Join(partialDir, "a.txt"))
+		assertions.True(os.IsNotExist(err), "parsed file must be removed")
+	})
+
+	//error tests
+	t.Run("FtpRemoveRecursiveMissing_ReturnsNotFoundError", func(t *testing.T) {
+		removed, err := ftpDownloader.RemoveRecursive(remoteFile("/tree"), nil)
+
+		assertions.Empty(removed)
+		assertions.True(errors.Is(err, models.ErrNotFound), fmt.Sprintf("err == %v, expected - not found", err))
+	})
+}
+
+//Parser, which fails on skip.txt lines
+type skipTxtListingParser struct{}
+
+func (parser *skipTxtListingParser) CanParse(line string) bool {
+	return strings.HasSuffix(line, " skip.txt")
+}
+
+func (parser *skipTxtListingParser) Parse(line string) (*models.RemoteFile, error) {
+	return nil, errors.New("skip.txt is not parsed")
+}
+
+//Session cache, which counts sessions found for resumption
+type countingSessionCache struct {
+	tls.ClientSessionCache
+	hits int
+}
+
+func (cache *countingSessionCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	session, ok := cache.ClientSessionCache.Get(sessionKey)
+	if ok {
+		cache.hits++
+	}
+	return session, ok
+}
+
+func Test_FtpDownloader_ImplicitTLS(t *testing.T) {
+	assertions := assertLib.New(t)
+
+	t.Run("ImplicitTLSDefaultsToPort990", func(t *testing.T) {
+		parsedDest, err := models.ParseDestination(&models.Destination{Url: "ftps://127.0.0.1/files",
+			Credentials: &models.Credentials{TLSMode: models.TLSImplicit}})
+		assertions.NoError(err)
+
+		addr, err := resolveControlAddr(parsedDest)
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal("127.0.0.1:990", addr)
+
+		tlsConfig, err := getTLSConfig(parsedDest, models.FtpOptions{TLSSessionReuse: true})
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal("127.0.0.1", tlsConfig.ServerName)
+		assertions.NotNil(tlsConfig.ClientSessionCache)
+	})
+
+	t.Run("FtpsWithExplicitTLSDefaultsToPort21", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: "ftps://127.0.0.1/files"})
+
+		addr, err := resolveControlAddr(parsedDest)
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal("127.0.0.1:21", addr)
+		tlsConfig, err := getTLSConfig(parsedDest, models.FtpOptions{})
+		assertions.NoError(err)
+		assertions.NotNil(tlsConfig, "ftps:// must use TLS without TLSConfig")
+	})
+
+	t.Run("FtpsKeepsPortOfUrl", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: "ftps://127.0.0.1:2990/files",
+			Credentials: &models.Credentials{TLSMode: models.TLSImplicit}})
+
+		addr, err := resolveControlAddr(parsedDest)
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal("127.0.0.1:2990", addr)
+	})
+
+	t.Run("FtpDefaultsToPlainPort21", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: "ftp://127.0.0.1/files"})
+
+		addr, err := resolveControlAddr(parsedDest)
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal("127.0.0.1:21", addr)
+		tlsConfig, err := getTLSConfig(parsedDest, models.FtpOptions{})
+		assertions.NoError(err)
+		assertions.Nil(tlsConfig)
+	})
+}
+
+//Client, which opens scripted control connection
+type fakeFtpClient struct {
+	IFtpClient
+	conn *fakeRawConn
+}
+
+func (client *fakeFtpClient) OpenRawConn() (goftp.RawConn, error) {
+	return client.conn, nil
+}
+
+func (client *fakeFtpClient) ControlAddr() string {
+	return "127.0.0.1:21"
+}
+
+func (client *fakeFtpClient) TLSConfig() *tls.Config {
+	return nil
+}
+
+//Control connection, which replies to commands with replies of scripted server
+type fakeRawConn struct {
+	//reply by command, i.e. "HASH /file.txt": "213 SHA-256 0-10 abc /file.txt"
+	replies map[string]string
+	//data sent over passive data connection by transfer command, i.e. "RETR /file.txt": "hello world".
+	//EPSV is supported, if set
+	data     map[string]string
+	listener net.Listener
+	commands []string
+}
+
+func (conn *fakeRawConn) SendCommand(f string, args ...interface{}) (int, string, error) {
+	command := fmt.Sprintf(f, args...)
+	conn.commands = append(conn.commands, command)
+	if command == "EPSV" && conn.data != nil {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return 0, "", err
+		}
+		conn.listener = listener
+		return replyEnteringExtendedPassiveMode, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", listener.Addr().(*net.TCPAddr).Port), nil
+	}
+	if data, ok := conn.data[command]; ok && conn.listener != nil {
+		listener := conn.listener
+		conn.listener = nil
+		go func() {
+			defer listener.Close()
+			dataConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			dataConn.Write([]byte(data))
+			dataConn.Close()
+		}()
+		return replyAboutToOpenDataConnection, "Opening data connection", nil
+	}
+	reply, ok := conn.replies[command]
+	if !ok {
+		return 500, "Unknown command", nil
+	}
+	code, err := strconv.Atoi(reply[:3])
+	return code, reply[4:], err
+}
+
+func (conn *fakeRawConn) PrepareDataConn() (func() (net.Conn, error), error) {
+	return nil, errors.New("not supported")
+}
+
+//Final reply of transfer
+func (conn *fakeRawConn) ReadResponse() (int, string, error) {
+	return replyClosingDataConnection, "Transfer complete", nil
+}
+
+func (conn *fakeRawConn) Close() error {
+	if conn.listener != nil {
+		conn.listener.Close()
+	}
+	return nil
+}
+
+func Test_FtpDownloader_Checksum(t *testing.T) {
+	assertions := assertLib.New(t)
+	sha256Sum := "169CD22282DA7F147CB491E559E9DD0123456789ABCDEF0123456789ABCDEF01"
+
+	t.Run("HashServer_ReturnsHashOfSelectedAlgorithm", func(t *testing.T) {
+		conn := &fakeRawConn{replies: map[string]string{
+			"OPTS HASH SHA-256":    "200 SHA-256",
+			"HASH /dir/report.csv": "213 SHA-256 0-49 " + sha256Sum + " /dir/report.csv",
+		}}
+		session := &ftpSession{conn: conn}
+
+		checksum, err := session.checksum("/dir/report.csv", models.ChecksumSHA256)
+
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal(strings.ToLower(sha256Sum), checksum)
+	})
+
+	t.Run("LegacyServer_ReturnsXMD5AndXCRC", func(t *testing.T) {
+		conn := &fakeRawConn{replies: map[string]string{
+			"XMD5 /report.csv": "250 0BF6FC53AC1BA2DE03FBD6B7A4A5E7C8",
+			"XCRC /report.csv": "250 /report.csv B4D0AE24",
+		}}
+		session := &ftpSession{conn: conn}
+
+		checksum, err := session.checksum("/report.csv", models.ChecksumMD5)
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal("0bf6fc53ac1ba2de03fbd6b7a4a5e7c8", checksum)
+		assertions.Equal([]string{"OPTS HASH MD5", "XMD5 /report.csv"}, conn.commands)
+
+		checksum, err = session.checksum("/report.csv", models.ChecksumCRC32)
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal("b4d0ae24", checksum)
+	})
+
+	t.Run("Encoding_SendsEncodedPath", func(t *testing.T) {
+		conn := &fakeRawConn{replies: map[string]string{
+			"XMD5 /\xee\xf2\xf7\xe5\xf2.csv": "250 0BF6FC53AC1BA2DE03FBD6B7A4A5E7C8",
+		}}
+		session := &ftpSession{conn: conn, encoding: charmap.Windows1251}
+
+		checksum, err := session.checksum("/отчет.csv", models.ChecksumMD5)
+
+		assertions.NoError(err, fmt.Sprintf("err == %v, expected - nil", err))
+		assertions.Equal("0bf6fc53ac1ba2de03fbd6b7a4a5e7c8", checksum)
+	})
+
+	//error tests
+	t.Run("LegacyServer_ReturnsErrorForSHA256", func(t *testing.T) {
+		session := &ftpSession{conn: &fakeRawConn{replies: map[string]string{}}}
+
+		_, err := session.checksum("/report.csv", models.ChecksumSHA256)
+
+		assertions.NotNil(err)
+		assertions.Contains(err.Error(), "does not support sha256 checksum")
+	})
+
+	t.Run("HashServer_MissingFileReturnsReplyError", func(t *testing.T) {
+		session := &ftpSession{conn: &fakeRawConn{replies: map[string]string{
+			"OPTS HASH MD5":     "200 MD5",
+			"HASH /missing.csv": "550 File not found",
+		}}}
+
+		_, err := session.checksum("/missing.csv", models.ChecksumMD5)
+
+		var replyErr *replyError
+		if assertions.True(errors.As(err, &replyErr), fmt.Sprintf("err == %v, expected - reply error", err)) {
+			assertions.Equal(replyFileUnavailable, replyErr.code)
+		}
+	})
+
+	t.Run("TestServer_ReturnsNotSupportedError", func(t *testing.T) {
+		s := ftpServerDriver.NewTestServerWithDriver(&ftpServerDriver.ServerDriver{
+			Settings: &server.Settings{ListenAddr: "127.0.0.1:0"},
+		})
+		defer s.Stop()
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{
+			Url:         "ftp://" + s.Addr() + "/file.txt",
+			Credentials: &models.Credentials{User: "test", Password: "test"},
+		})
+
+		checksum, err := (&FtpDownloader{}).Checksum(remoteFile, models.ChecksumMD5)
+
+		assertions.NotNil(err)
+		assertions.Contains(err.Error(), "does not support md5 checksum")
+		assertions.Equal("", checksum)
+	})
+}
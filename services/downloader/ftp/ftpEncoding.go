@@ -0,0 +1,76 @@
+package ftp
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+//Single byte encodings of file names. UTF-8 names are sent as is
+var ftpEncodings = map[models.FtpEncoding]encoding.Encoding{
+	models.FtpCP1251: charmap.Windows1251,
+	models.FtpKOI8R:  charmap.KOI8R,
+	models.FtpLatin1: charmap.ISO8859_1,
+}
+
+//Encodes file name or path for control connection. Returns error, if name has characters, which are missing
+//in the encoding
+func encodeName(enc encoding.Encoding, name string) (string, error) {
+	if enc == nil {
+		return name, nil
+	}
+	encoded, err := enc.NewEncoder().String(name)
+	if err != nil {
+		return "", fmt.Errorf("ftp file name %s can not be encoded: %v", name, err)
+	}
+	return encoded, nil
+}
+
+//Decodes file name, listing line or reply received from server
+func decodeName(enc encoding.Encoding, name string) string {
+	if enc == nil {
+		return name
+	}
+	decoded, err := enc.NewDecoder().String(name)
+	if err != nil {
+		return name
+	}
+	return decoded
+}
+
+//Converts CRLF line endings of ASCII transfer to LF
+type crlfWriter struct {
+	writer io.Writer
+	//CR at the end of the previous chunk, which may precede LF of the next one
+	pendingCR bool
+}
+
+func (w *crlfWriter) Write(buf []byte) (int, error) {
+	converted := make([]byte, 0, len(buf)+1)
+	for _, b := range buf {
+		if w.pendingCR && b != '\n' {
+			converted = append(converted, '\r')
+		}
+		w.pendingCR = b == '\r'
+		if !w.pendingCR {
+			converted = append(converted, b)
+		}
+	}
+	if _, err := w.writer.Write(converted); err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+//Writes CR, which was not followed by LF at the end of transfer
+func (w *crlfWriter) Flush() error {
+	if !w.pendingCR {
+		return nil
+	}
+	w.pendingCR = false
+	_, err := w.writer.Write([]byte{'\r'})
+	return err
+}
@@ -0,0 +1,59 @@
+package ftp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Lists directory of destination with MLSD, or with LIST on servers without MLSD. Listing commands are sent
+//without arguments after CWD, as some legacy servers do not accept path in LIST. LIST lines are parsed by the
+//first of parsers, which recognizes them. Lines, which were not parsed, are returned as warnings
+func (session *ftpSession) list(destination *models.ParsedDestination, parsers []ListingParser) ([]*models.RemoteFile, []ListingWarning, error) {
+	dirPath := destination.GetPath()
+	if dirPath == "" {
+		dirPath = "/"
+	}
+	if err := session.command(replyFileActionOk, "CWD", dirPath); err != nil {
+		var replyErr *replyError
+		if errors.As(err, &replyErr) && replyErr.code == replyFileUnavailable {
+			return nil, nil, models.NewNotFoundError(destination.Url)
+		}
+		return nil, nil, err
+	}
+
+	lines, err := session.readLines("MLSD")
+	if err == nil {
+		files, warnings := parseListing(destination, lines, []ListingParser{&MlsdListingParser{}})
+		return files, warnings, nil
+	}
+	var replyErr *replyError
+	if !errors.As(err, &replyErr) || !commandNotSupported(replyErr.code) {
+		return nil, nil, err
+	}
+
+	lines, err = session.readLines("LIST")
+	if err != nil {
+		return nil, nil, err
+	}
+	files, warnings := parseListing(destination, lines, parsers)
+	return files, warnings, nil
+}
+
+//Transfers listing and returns its non-empty lines, decoded with encoding of file names
+func (session *ftpSession) readLines(command string) ([]string, error) {
+	lines := make([]string, 0)
+	err := session.transfer(command, "", func(data io.Reader) error {
+		scanner := bufio.NewScanner(data)
+		for scanner.Scan() {
+			if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+				lines = append(lines, decodeName(session.encoding, line))
+			}
+		}
+		return scanner.Err()
+	})
+	return lines, err
+}
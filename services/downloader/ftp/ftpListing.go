@@ -0,0 +1,296 @@
+package ftp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Parser of LIST reply line. Format of LIST is not standardized, so each line is parsed by the first parser,
+//which recognizes it
+type ListingParser interface {
+	//Returns true, if parser recognizes the line format
+	CanParse(line string) bool
+	//Parses line into remote file with Name, Size, Lastmod and IsDir. Returns nil file for lines, which are not
+	//entries, i.e. "total 10" or current directory
+	Parse(line string) (*models.RemoteFile, error)
+}
+
+//Parsers used by Browse after FtpDownloader.ListingParsers
+var defaultListingParsers = []ListingParser{
+	&MlsdListingParser{},
+	&EplfListingParser{},
+	&UnixListingParser{},
+	&DosListingParser{},
+}
+
+//Line of listing, which was not parsed
+type ListingWarning struct {
+	Line string
+	Err  error
+}
+
+func (ftpDownloader *FtpDownloader) listingParsers() []ListingParser {
+	return append(append([]ListingParser{}, ftpDownloader.ListingParsers...), defaultListingParsers...)
+}
+
+//Reports lines, which were not parsed, to OnListingWarnings
+func (ftpDownloader *FtpDownloader) reportListingWarnings(destination *models.ParsedDestination, warnings []ListingWarning) {
+	if len(warnings) > 0 && ftpDownloader.OnListingWarnings != nil {
+		ftpDownloader.OnListingWarnings(destination.Url, warnings)
+	}
+}
+
+//Parses listing lines into remote files of destination directory. Lines, which are not parsed, are skipped and
+//returned as warnings
+func parseListing(destination *models.ParsedDestination, lines []string, parsers []ListingParser) ([]*models.RemoteFile, []ListingWarning) {
+	result := make([]*models.RemoteFile, 0, len(lines))
+	warnings := make([]ListingWarning, 0)
+	for _, line := range lines {
+		remoteFile, err := parseListingLine(line, parsers)
+		if err != nil {
+			warnings = append(warnings, ListingWarning{Line: line, Err: err})
+			continue
+		}
+		if remoteFile == nil || remoteFile.Name == "." || remoteFile.Name == ".." {
+			continue
+		}
+		remoteFile.Path = destination.GetPath()
+		remoteFile.ParsedDestination = destination
+		result = append(result, remoteFile)
+	}
+	return result, warnings
+}
+
+func parseListingLine(line string, parsers []ListingParser) (*models.RemoteFile, error) {
+	for _, parser := range parsers {
+		if parser.CanParse(line) {
+			return parser.Parse(line)
+		}
+	}
+	return nil, fmt.Errorf("unsupported ftp listing format")
+}
+
+//Machine readable MLSD listing: "type=file;size=11;modify=20200101120000; name"
+type MlsdListingParser struct{}
+
+func (parser *MlsdListingParser) CanParse(line string) bool {
+	separator := strings.Index(line, "; ")
+	return separator > 0 && strings.Contains(line[:separator], "=")
+}
+
+func (parser *MlsdListingParser) Parse(line string) (*models.RemoteFile, error) {
+	remoteFile := &models.RemoteFile{}
+	name, entryType, err := parseMlstLine(line, remoteFile)
+	if err != nil {
+		return nil, err
+	}
+	//current and parent directories are listed by some servers
+	if entryType == "cdir" || entryType == "pdir" {
+		return nil, nil
+	}
+	remoteFile.Name = name
+	return remoteFile, nil
+}
+
+//Easily Parsed LIST Format: "+i8388621.29609,m824255902,/,\tdir" or "+r,s1024,m824255902,\tfile.txt"
+type EplfListingParser struct{}
+
+func (parser *EplfListingParser) CanParse(line string) bool {
+	return strings.HasPrefix(line, "+") && strings.Contains(line, "\t")
+}
+
+func (parser *EplfListingParser) Parse(line string) (*models.RemoteFile, error) {
+	tab := strings.Index(line, "\t")
+	remoteFile := &models.RemoteFile{Name: line[tab+1:], Size: models.UnknownSize}
+	for _, fact := range strings.Split(line[1:tab], ",") {
+		if fact == "" {
+			continue
+		}
+		switch fact[0] {
+		case '/':
+			remoteFile.IsDir = true
+		case 's':
+			size, err := strconv.ParseInt(fact[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EPLF size fact: %s", fact)
+			}
+			remoteFile.Size = size
+		case 'm':
+			seconds, err := strconv.ParseInt(fact[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EPLF modification time fact: %s", fact)
+			}
+			remoteFile.Lastmod = time.Unix(seconds, 0).UTC()
+		}
+	}
+	if remoteFile.IsDir && remoteFile.Size == models.UnknownSize {
+		remoteFile.Size = 0
+	}
+	return remoteFile, nil
+}
+
+//LIST time formats of unix ls
+const (
+	listTimeLayout = "Jan 2 15:04 2006"
+	listYearLayout = "Jan 2 2006"
+)
+
+//Unix ls format: "drwxr-xr-x 1 owner group 4096 Jan 2 15:04 name". Group column is optional, times without
+//year are of the last 12 months
+type UnixListingParser struct {
+	//current time, which year of recent files is taken from. Defaults to time.Now
+	Now func() time.Time
+}
+
+func (parser *UnixListingParser) CanParse(line string) bool {
+	if strings.HasPrefix(line, "total ") {
+		return true
+	}
+	if len(line) < 10 || !strings.ContainsRune("-dlbcps", rune(line[0])) {
+		return false
+	}
+	for _, char := range line[1:10] {
+		if !strings.ContainsRune("rwxsStTl-", char) {
+			return false
+		}
+	}
+	return true
+}
+
+func (parser *UnixListingParser) Parse(line string) (*models.RemoteFile, error) {
+	if strings.HasPrefix(line, "total ") {
+		return nil, nil
+	}
+	now := time.Now()
+	if parser.Now != nil {
+		now = parser.Now()
+	}
+
+	fields, offsets := make([]string, 0), make([]int, 0)
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		end := strings.IndexByte(line[i:], ' ')
+		if end < 0 {
+			end = len(line) - i
+		}
+		fields, offsets = append(fields, line[i:i+end]), append(offsets, i)
+		i += end
+	}
+
+	//size column precedes month, so it is found by month name
+	for month := 3; month+3 < len(fields); month++ {
+		size, err := strconv.ParseInt(fields[month-1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, err := time.Parse("Jan", fields[month]); err != nil {
+			continue
+		}
+		lastmod, err := parseListTime(fields[month], fields[month+1], fields[month+2], now.UTC())
+		if err != nil {
+			return nil, fmt.Errorf("invalid unix listing time: %v", err)
+		}
+		remoteFile := &models.RemoteFile{Name: line[offsets[month+3]:], Size: size, Lastmod: lastmod, IsDir: line[0] == 'd',
+			Metadata: listMetadata(line[:10], fields[:month-1])}
+		if line[0] == 'l' {
+			if arrow := strings.Index(remoteFile.Name, " -> "); arrow >= 0 {
+				remoteFile.Name = remoteFile.Name[:arrow]
+			}
+		}
+		return remoteFile, nil
+	}
+	return nil, fmt.Errorf("invalid unix listing line")
+}
+
+//Metadata of unix listing: mode of "drwxr-xr-x" and owner and group of columns after links count
+func listMetadata(modeString string, columns []string) *models.FileMetadata {
+	mode := models.ParseModeString(modeString)
+	metadata := &models.FileMetadata{Mode: &mode}
+	if len(columns) >= 3 {
+		metadata.Owner = columns[2]
+	}
+	if len(columns) >= 4 {
+		metadata.Group = columns[3]
+	}
+	return metadata
+}
+
+func parseListTime(month, day, timeOrYear string, now time.Time) (time.Time, error) {
+	if !strings.Contains(timeOrYear, ":") {
+		return time.Parse(listYearLayout, month+" "+day+" "+timeOrYear)
+	}
+	lastmod, err := time.Parse(listTimeLayout, month+" "+day+" "+timeOrYear+" "+strconv.Itoa(now.Year()))
+	if err != nil {
+		return lastmod, err
+	}
+	//time in future belongs to the previous year
+	if lastmod.After(now.Add(24 * time.Hour)) {
+		lastmod = lastmod.AddDate(-1, 0, 0)
+	}
+	return lastmod, nil
+}
+
+//DOS date formats of Windows/IIS listing
+var dosTimeLayouts = []string{"01-02-06 03:04PM", "01-02-2006 03:04PM", "01-02-06 15:04", "01-02-2006 15:04"}
+
+//Windows/IIS DOS format: "02-01-21  10:00AM       <DIR>          dir" or "02-01-21  10:00AM  1024 file.txt"
+type DosListingParser struct{}
+
+func (parser *DosListingParser) CanParse(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || len(fields[0]) < 8 {
+		return false
+	}
+	date := fields[0]
+	return date[2] == '-' && date[5] == '-' && strings.Contains(fields[1], ":")
+}
+
+func (parser *DosListingParser) Parse(line string) (*models.RemoteFile, error) {
+	//name may contain spaces, so it is taken by offset of the fourth column
+	rest := line
+	fields := make([]string, 0, 3)
+	for len(fields) < 3 {
+		rest = strings.TrimLeft(rest, " ")
+		end := strings.IndexByte(rest, ' ')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid dos listing line")
+		}
+		fields = append(fields, rest[:end])
+		rest = rest[end:]
+	}
+	remoteFile := &models.RemoteFile{Name: strings.TrimLeft(rest, " ")}
+	if remoteFile.Name == "" {
+		return nil, fmt.Errorf("invalid dos listing line")
+	}
+
+	lastmod, err := parseDosTime(fields[0] + " " + strings.ToUpper(fields[1]))
+	if err != nil {
+		return nil, err
+	}
+	remoteFile.Lastmod = lastmod
+	if strings.EqualFold(fields[2], "<DIR>") {
+		remoteFile.IsDir = true
+		return remoteFile, nil
+	}
+	remoteFile.Size, err = strconv.ParseInt(strings.Replace(fields[2], ",", "", -1), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dos listing size: %s", fields[2])
+	}
+	return remoteFile, nil
+}
+
+func parseDosTime(value string) (time.Time, error) {
+	for _, layout := range dosTimeLayouts {
+		if lastmod, err := time.Parse(layout, value); err == nil {
+			return lastmod, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid dos listing time: %s", value)
+}
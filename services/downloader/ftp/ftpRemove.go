@@ -0,0 +1,105 @@
+package ftp
+
+import (
+	"path"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Removes file, or directory with all files and subdirectories depth-first. Symbolic links are removed, not followed.
+//With options.DryRun nothing is removed. Returns removed paths in deletion order, including the ones removed before
+//an error
+func (ftpDownloader *FtpDownloader) RemoveRecursive(remoteFile *models.RemoteFile, options *models.RemoveOptions) ([]string, error) {
+	ftpClient, err := ftpDownloader.getClient(remoteFile.ParsedDestination)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ftpClient.Close()
+
+	return ftpDownloader.removeRecursive(ftpClient, remoteFile, options)
+}
+
+//Walks directory tree over single control connection and removes files before their directories
+type ftpTreeRemover struct {
+	downloader *FtpDownloader
+	session    *ftpSession
+	parsers    []ListingParser
+	dryRun     bool
+	removed    []string
+}
+
+func (ftpDownloader *FtpDownloader) removeRecursive(client IFtpClient, remoteFile *models.RemoteFile, options *models.RemoveOptions) ([]string, error) {
+	if options == nil {
+		options = &models.RemoveOptions{}
+	}
+	session, err := ftpDownloader.openSession(client, remoteFile.ParsedDestination)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	//files of Browse have destination of their directory
+	filePath := path.Join(remoteFile.Path, remoteFile.Name)
+	stat, err := session.stat(withPath(remoteFile.ParsedDestination, filePath))
+	if err != nil {
+		return nil, err
+	}
+	remover := &ftpTreeRemover{downloader: ftpDownloader, session: session, parsers: ftpDownloader.listingParsers(),
+		dryRun: options.DryRun, removed: make([]string, 0)}
+	if stat.IsDir {
+		err = remover.removeDir(remoteFile.ParsedDestination, filePath)
+	} else {
+		err = remover.remove("DELE", filePath)
+	}
+	return remover.removed, err
+}
+
+//Directory is listed completely before removal. Entries of lines, which were not parsed, are reported to
+//OnListingWarnings and left, so RMD of their directory fails
+func (remover *ftpTreeRemover) removeDir(destination *models.ParsedDestination, dirPath string) error {
+	dirDestination := withPath(destination, dirPath+"/")
+	entries, warnings, err := remover.session.list(dirDestination, remover.parsers)
+	if err != nil {
+		return err
+	}
+	remover.downloader.reportListingWarnings(dirDestination, warnings)
+	for _, entry := range entries {
+		entryPath := path.Join(dirPath, entry.Name)
+		if entry.IsDir {
+			err = remover.removeDir(destination, entryPath)
+		} else {
+			err = remover.remove("DELE", entryPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	//listing changed working directory, and some servers do not remove current one
+	if !remover.dryRun {
+		if err := remover.session.command(replyFileActionOk, "CWD", path.Dir(dirPath)); err != nil {
+			return err
+		}
+	}
+	return remover.remove("RMD", dirPath)
+}
+
+func (remover *ftpTreeRemover) remove(command, filePath string) error {
+	if !remover.dryRun {
+		if err := remover.session.command(replyFileActionOk, command, filePath); err != nil {
+			return err
+		}
+	}
+	remover.removed = append(remover.removed, filePath)
+	return nil
+}
+
+//Returns copy of destination with another path
+func withPath(destination *models.ParsedDestination, filePath string) *models.ParsedDestination {
+	child := *destination
+	childUrl := *destination.ParsedUrl
+	childUrl.Path = filePath
+	child.ParsedUrl = &childUrl
+	child.Url = childUrl.String()
+	return &child
+}
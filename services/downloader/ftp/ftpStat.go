@@ -0,0 +1,218 @@
+package ftp
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//FTP reply codes used by stat
+const (
+	replyFileStatus        = 213
+	replyFileActionOk      = 250
+	replySyntaxError       = 500
+	replyParamSyntaxError  = 501
+	replyNotImplemented    = 502
+	replyParamNotSupported = 504
+	replyFileUnavailable   = 550
+)
+
+//MLST and MDTM time formats
+const (
+	mlstTimeLayout           = "20060102150405"
+	mlstFractionalTimeLayout = "20060102150405.999999999"
+)
+
+//Stats single path with MLST. If server does not support MLST, falls back to CWD for directories and SIZE in
+//binary mode and MDTM for files. Parent directory is never listed
+func (ftpDownloader *FtpDownloader) stat(client IFtpClient, destination *models.ParsedDestination) (*models.RemoteFile, error) {
+	session, err := ftpDownloader.openSession(client, destination)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	return session.stat(destination)
+}
+
+func (session *ftpSession) stat(destination *models.ParsedDestination) (*models.RemoteFile, error) {
+	filePath := destination.GetPath()
+	if filePath == "" {
+		filePath = "/"
+	}
+	dir, name := path.Split(strings.TrimSuffix(filePath, "/"))
+	remoteFile := &models.RemoteFile{Name: name, Path: dir, ParsedDestination: destination}
+
+	code, msg, err := session.send("MLST", filePath)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case code == replyFileActionOk:
+		if err := parseMlstFacts(msg, remoteFile); err != nil {
+			return nil, err
+		}
+		return remoteFile, nil
+	case code == replyFileUnavailable:
+		return nil, models.NewNotFoundError(destination.Url)
+	case !commandNotSupported(code):
+		return nil, fmt.Errorf("ftp MLST %s failed: %d %s", filePath, code, msg)
+	}
+
+	//directories can not be stat by SIZE
+	code, _, err = session.send("CWD", filePath)
+	if err != nil {
+		return nil, err
+	}
+	if code == replyFileActionOk {
+		remoteFile.IsDir = true
+		return remoteFile, nil
+	}
+
+	//some servers refuse SIZE in ASCII mode with 550, so it means missing file only in binary mode
+	code, _, err = session.send("TYPE", "I")
+	if err != nil {
+		return nil, err
+	}
+	binaryMode := code == replyCommandOk
+
+	code, msg, err = session.send("SIZE", filePath)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case code == replyFileStatus:
+		remoteFile.Size, err = strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ftp SIZE %s returned invalid size: %s", filePath, msg)
+		}
+	case code == replyFileUnavailable && binaryMode:
+		return nil, models.NewNotFoundError(destination.Url)
+	default:
+		return nil, fmt.Errorf("ftp SIZE %s failed: %d %s", filePath, code, msg)
+	}
+
+	//modification time is optional. Some servers answer MDTM with 250 instead of 213
+	code, msg, err = session.send("MDTM", filePath)
+	if err != nil {
+		return nil, err
+	}
+	if code == replyFileStatus || code == replyFileActionOk {
+		if lastmod, err := parseMlstTime(strings.TrimSpace(msg)); err == nil {
+			remoteFile.Lastmod = lastmod
+		}
+	}
+	return remoteFile, nil
+}
+
+func commandNotSupported(code int) bool {
+	return code == replySyntaxError || code == replyParamSyntaxError || code == replyNotImplemented || code == replyParamNotSupported
+}
+
+//Parses "type=file;size=11;modify=20200101120000; /path" line of MLST reply
+func parseMlstFacts(msg string, remoteFile *models.RemoteFile) error {
+	for _, line := range strings.Split(msg, "\n") {
+		if _, entryType, err := parseMlstLine(strings.TrimSpace(line), remoteFile); err != nil || entryType != "" {
+			return err
+		}
+	}
+	return fmt.Errorf("unexpected ftp MLST reply: %s", msg)
+}
+
+//Parses facts of MLST reply or MLSD listing line into remoteFile. Returns pathname and type fact of the entry.
+//Type is empty, if line has no facts
+func parseMlstLine(line string, remoteFile *models.RemoteFile) (string, string, error) {
+	separator := strings.Index(line, "; ")
+	if separator < 0 || !strings.Contains(line[:separator], "=") {
+		return "", "", nil
+	}
+	entryType := "file"
+	remoteFile.Size = models.UnknownSize
+	metadata := &models.FileMetadata{Facts: make(map[string]string)}
+	remoteFile.Metadata = metadata
+	var mode *os.FileMode
+	for _, fact := range strings.Split(line[:separator], ";") {
+		parts := strings.SplitN(fact, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name, value := strings.ToLower(parts[0]), parts[1]
+		metadata.Facts[name] = value
+		//invalid metadata facts are kept in Facts only, as they are not required to list files
+		switch name {
+		case "unix.mode":
+			if bits, err := strconv.ParseUint(value, 8, 32); err == nil {
+				unixMode := unixFileMode(uint32(bits))
+				mode = &unixMode
+			}
+		case "unix.uid":
+			if uid, err := strconv.ParseUint(value, 10, 32); err == nil {
+				id := uint32(uid)
+				metadata.Uid = &id
+			}
+		case "unix.gid":
+			if gid, err := strconv.ParseUint(value, 10, 32); err == nil {
+				id := uint32(gid)
+				metadata.Gid = &id
+			}
+		case "unix.owner":
+			metadata.Owner = value
+		case "unix.group":
+			metadata.Group = value
+		case "type":
+			entryType = strings.ToLower(value)
+			switch entryType {
+			case "dir", "cdir", "pdir":
+				remoteFile.IsDir = true
+			}
+		case "size", "sizd":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return "", entryType, fmt.Errorf("invalid ftp MLST size fact: %s", value)
+			}
+			remoteFile.Size = size
+		case "modify":
+			lastmod, err := parseMlstTime(value)
+			if err != nil {
+				return "", entryType, fmt.Errorf("invalid ftp MLST modify fact: %s", value)
+			}
+			remoteFile.Lastmod = lastmod
+		}
+	}
+	if remoteFile.IsDir && remoteFile.Size == models.UnknownSize {
+		remoteFile.Size = 0
+	}
+	if mode != nil && remoteFile.IsDir {
+		*mode |= os.ModeDir
+	}
+	metadata.Mode = mode
+	return line[separator+2:], entryType, nil
+}
+
+//Converts unix permission, setuid, setgid and sticky bits to os.FileMode
+func unixFileMode(bits uint32) os.FileMode {
+	mode := os.FileMode(bits & 0777)
+	if bits&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+//MLST and MDTM times are UTC, optionally with fractional seconds
+func parseMlstTime(value string) (time.Time, error) {
+	if strings.Contains(value, ".") {
+		return time.Parse(mlstFractionalTimeLayout, value)
+	}
+	return time.Parse(mlstTimeLayout, value)
+}
@@ -0,0 +1,181 @@
+package ftp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"github.com/secsy/goftp"
+)
+
+//FTP reply codes of login
+const (
+	replyServiceReady = 220
+	replyLoggedIn     = 230
+	replyAuthOk       = 234
+	replyNeedPassword = 331
+)
+
+//Time to wait for close_notify alert of server after CCC
+const cccShutdownTimeout = 5 * time.Second
+
+//Settings of control connections, which send CCC after login
+type clearControlConfig struct {
+	goftp.Config
+	dataProtection models.FtpDataProtection
+}
+
+//Control connection, which TLS is shut down after CCC. Goftp keeps its control connection private, so such
+//connections are made by the downloader itself. Data connections are prepared by ftpSession, not by the connection
+type clearControlConn struct {
+	conn net.Conn
+	//TCP connection under TLS
+	plainConn net.Conn
+	reader    *textproto.Reader
+	writer    *textproto.Writer
+	timeout   time.Duration
+}
+
+//Dials control connection with implicit or explicit TLS, logs in, sets data protection level and sends CCC
+func dialClearControlConn(addr string, config *clearControlConfig) (goftp.RawConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	control := &clearControlConn{plainConn: conn, timeout: config.Timeout}
+	if config.TLSMode == goftp.TLSImplicit {
+		control.setConn(tls.Client(conn, config.TLSConfig))
+	} else {
+		control.setConn(conn)
+	}
+
+	if err := control.logIn(config); err != nil {
+		control.Close()
+		return nil, err
+	}
+	return control, nil
+}
+
+func (control *clearControlConn) setConn(conn net.Conn) {
+	control.conn = conn
+	control.reader = textproto.NewReader(bufio.NewReader(conn))
+	control.writer = textproto.NewWriter(bufio.NewWriter(conn))
+}
+
+func (control *clearControlConn) logIn(config *clearControlConfig) error {
+	if err := control.expect(replyServiceReady); err != nil {
+		return err
+	}
+	if config.TLSMode == goftp.TLSExplicit {
+		if err := control.command(replyAuthOk, "AUTH TLS"); err != nil {
+			return err
+		}
+		control.setConn(tls.Client(control.conn, config.TLSConfig))
+	}
+
+	if config.User != "" {
+		code, msg, err := control.SendCommand("USER %s", config.User)
+		if err != nil {
+			return err
+		}
+		if code == replyNeedPassword {
+			if code, msg, err = control.SendCommand("PASS %s", config.Password); err != nil {
+				return err
+			}
+		}
+		if code != replyLoggedIn {
+			return &replyError{command: "USER", code: code, message: msg}
+		}
+	}
+
+	if err := control.command(replyCommandOk, "PBSZ 0"); err != nil {
+		return err
+	}
+	if err := control.command(replyCommandOk, "PROT "+string(config.dataProtection)); err != nil {
+		return err
+	}
+	if err := control.command(replyCommandOk, "CCC"); err != nil {
+		return err
+	}
+	return control.shutdownTLS()
+}
+
+//Exchanges close_notify alerts with server and continues on the underlying TCP connection
+func (control *clearControlConn) shutdownTLS() error {
+	tlsConn, ok := control.conn.(*tls.Conn)
+	if !ok {
+		return errors.New("ftp control connection is not protected with TLS")
+	}
+	if err := tlsConn.CloseWrite(); err != nil {
+		return err
+	}
+	//server alert is read as EOF. Some servers do not send it and go on in clear text at once
+	control.plainConn.SetReadDeadline(time.Now().Add(cccShutdownTimeout))
+	if _, err := io.Copy(ioutil.Discard, tlsConn); err != nil && !isTimeout(err) {
+		return fmt.Errorf("ftp CCC failed: %v", err)
+	}
+	control.plainConn.SetReadDeadline(time.Time{})
+	control.setConn(control.plainConn)
+	return nil
+}
+
+func (control *clearControlConn) setDeadline() {
+	if control.timeout > 0 {
+		control.conn.SetDeadline(time.Now().Add(control.timeout))
+	}
+}
+
+func (control *clearControlConn) expect(expected int) error {
+	code, msg, err := control.ReadResponse()
+	if err != nil {
+		return err
+	}
+	if code != expected {
+		return &replyError{command: "connect", code: code, message: msg}
+	}
+	return nil
+}
+
+func (control *clearControlConn) command(expected int, command string) error {
+	code, msg, err := control.SendCommand("%s", command)
+	if err != nil {
+		return err
+	}
+	if code != expected {
+		return &replyError{command: command, code: code, message: msg}
+	}
+	return nil
+}
+
+func (control *clearControlConn) SendCommand(f string, args ...interface{}) (int, string, error) {
+	control.setDeadline()
+	if err := control.writer.PrintfLine(f, args...); err != nil {
+		return 0, "", fmt.Errorf("error writing ftp command: %v", err)
+	}
+	return control.ReadResponse()
+}
+
+func (control *clearControlConn) ReadResponse() (int, string, error) {
+	control.setDeadline()
+	return control.reader.ReadResponse(0)
+}
+
+func (control *clearControlConn) PrepareDataConn() (func() (net.Conn, error), error) {
+	return nil, errors.New("data connections are prepared by ftp session")
+}
+
+func (control *clearControlConn) Close() error {
+	return control.plainConn.Close()
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,381 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Token is refreshed earlier than it really expires to survive clock skew and request latency
+const oauth2ExpirySkew = 30 * time.Second
+
+type oauth2Token struct {
+	accessToken string
+	tokenType   string
+	expires     time.Time
+}
+
+//Cache of OAuth2 tokens shared by all requests of HttpDownloader
+type oauth2TokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]*oauth2Token
+}
+
+func (cache *oauth2TokenCache) get(key string) *oauth2Token {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	token, ok := cache.tokens[key]
+	if !ok || (!token.expires.IsZero() && time.Now().After(token.expires)) {
+		return nil
+	}
+	return token
+}
+
+func (cache *oauth2TokenCache) put(key string, token *oauth2Token) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.tokens == nil {
+		cache.tokens = make(map[string]*oauth2Token)
+	}
+	cache.tokens[key] = token
+}
+
+func (cache *oauth2TokenCache) invalidate(key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	delete(cache.tokens, key)
+}
+
+//Sends request with authentication configured in destination credentials.
+//Retries once on 401 for Digest challenge and for expired OAuth2 token
+func (httpDownloader *HttpDownloader) do(client *http.Client, req *http.Request, destination *models.ParsedDestination) (*http.Response, error) {
+	auth := destination.Credentials.HttpAuth
+	if err := httpDownloader.authorize(client, req, destination); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || auth == nil {
+		return resp, err
+	}
+
+	switch auth.Type {
+	case models.HttpAuthDigest:
+		challenge := resp.Header.Get("WWW-Authenticate")
+		if !strings.HasPrefix(strings.ToLower(challenge), "digest ") {
+			return resp, nil
+		}
+		retry, err := cloneRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		authorization, err := digestAuthorization(challenge, retry, destination.GetUser(), destination.GetPassword())
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		retry.Header.Set("Authorization", authorization)
+		return client.Do(retry)
+	case models.HttpAuthOAuth2:
+		if auth.OAuth2 == nil {
+			return resp, nil
+		}
+		httpDownloader.tokens.invalidate(oauth2CacheKey(auth.OAuth2))
+		retry, err := cloneRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := httpDownloader.authorize(client, retry, destination); err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		return client.Do(retry)
+	}
+	return resp, nil
+}
+
+func (httpDownloader *HttpDownloader) authorize(client *http.Client, req *http.Request, destination *models.ParsedDestination) error {
+	user := destination.GetUser()
+	password := destination.GetPassword()
+	auth := destination.Credentials.HttpAuth
+
+	if auth == nil {
+		if user != "" || password != "" {
+			req.SetBasicAuth(user, password)
+		}
+		return nil
+	}
+
+	switch auth.Type {
+	case models.HttpAuthBasic:
+		req.SetBasicAuth(user, password)
+	case models.HttpAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	case models.HttpAuthDigest:
+		//credentials are sent in response to server challenge
+	case models.HttpAuthOAuth2:
+		if auth.OAuth2 == nil {
+			return errors.New("oauth2 settings are not set")
+		}
+		token, err := httpDownloader.getOAuth2Token(client, auth.OAuth2)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", token.tokenType+" "+token.accessToken)
+	case models.HttpAuthApiKey:
+		if auth.ApiKey == nil {
+			return errors.New("api key settings are not set")
+		}
+		if auth.ApiKey.InQuery {
+			query := req.URL.Query()
+			query.Set(auth.ApiKey.Name, auth.ApiKey.Value)
+			req.URL.RawQuery = query.Encode()
+		} else {
+			req.Header.Set(auth.ApiKey.Name, auth.ApiKey.Value)
+		}
+	case models.HttpAuthHmac:
+		if auth.Hmac == nil {
+			return errors.New("hmac settings are not set")
+		}
+		return signHmac(req, auth.Hmac)
+	default:
+		return fmt.Errorf("unknown http auth type: %s", auth.Type)
+	}
+	return nil
+}
+
+func oauth2CacheKey(settings *models.OAuth2ClientCredentials) string {
+	secretHash := sha256.Sum256([]byte(settings.ClientSecret))
+	return strings.Join([]string{settings.TokenUrl, settings.ClientId, hex.EncodeToString(secretHash[:]),
+		strings.Join(settings.Scopes, " ")}, "|")
+}
+
+func (httpDownloader *HttpDownloader) getOAuth2Token(client *http.Client, settings *models.OAuth2ClientCredentials) (*oauth2Token, error) {
+	key := oauth2CacheKey(settings)
+	if token := httpDownloader.tokens.get(key); token != nil {
+		return token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(settings.Scopes) > 0 {
+		form.Set("scope", strings.Join(settings.Scopes, " "))
+	}
+	req, err := http.NewRequest("POST", settings.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(settings.ClientId), url.QueryEscape(settings.ClientSecret))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth2 token request failed: %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.AccessToken == "" {
+		return nil, errors.New("oauth2 token response has no access_token")
+	}
+
+	token := &oauth2Token{accessToken: body.AccessToken, tokenType: "Bearer"}
+	if strings.ToLower(body.TokenType) != "bearer" && body.TokenType != "" {
+		token.tokenType = body.TokenType
+	}
+	if body.ExpiresIn > 0 {
+		token.expires = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - oauth2ExpirySkew)
+	}
+	httpDownloader.tokens.put(key, token)
+	return token, nil
+}
+
+func signHmac(req *http.Request, settings *models.HmacSigning) error {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+	bodyHash := sha256.Sum256(body)
+
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.URL.RequestURI(),
+		req.Header.Get("Date"),
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(settings.Secret))
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	header := settings.Header
+	if header == "" {
+		header = "Authorization"
+	}
+	req.Header.Set(header, fmt.Sprintf(`HMAC-SHA256 keyId="%s",signature="%s"`, settings.KeyId, signature))
+	return nil
+}
+
+//Reads request body without consuming it
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errors.New("request body can not be resent")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+//Builds Authorization header value in response to Digest challenge (RFC 7616)
+func digestAuthorization(challenge string, req *http.Request, user, password string) (string, error) {
+	params := parseAuthParams(challenge[len("digest "):])
+	realm, nonce := params["realm"], params["nonce"]
+	if nonce == "" {
+		return "", errors.New("digest challenge has no nonce")
+	}
+
+	algorithm := params["algorithm"]
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm: %s", algorithm)
+	}
+	h := func(s string) string {
+		hasher := newHash()
+		io.WriteString(hasher, s)
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := "00000001"
+
+	ha1 := h(user + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	uri := req.URL.RequestURI()
+	ha2 := h(req.Method + ":" + uri)
+
+	qop := ""
+	for _, option := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	var response string
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, nonce, nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		user, realm, nonce, uri, response)
+	if algorithm != "" {
+		authorization += ", algorithm=" + algorithm
+	}
+	if qop != "" {
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if opaque, ok := params["opaque"]; ok {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return authorization, nil
+}
+
+//Parses comma separated key=value pairs with optionally quoted values
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var builder strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				builder.WriteByte(s[i])
+			}
+			value = builder.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	return params
+}
@@ -0,0 +1,186 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"github.com/stretchr/testify/require"
+)
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+//Verifies Digest auth with qop=auth and MD5 algorithm
+func digestHandler(handler http.HandlerFunc, user, password, realm, nonce string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Digest ") {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", nonce="%s", qop="auth", opaque="xyz"`, realm, nonce))
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		params := parseAuthParams(strings.TrimPrefix(authorization, "Digest "))
+		ha1 := md5Hex(user + ":" + realm + ":" + password)
+		ha2 := md5Hex(r.Method + ":" + params["uri"])
+		expected := md5Hex(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+		if params["username"] != user || params["response"] != expected || params["opaque"] != "xyz" {
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func Test_HttpDownloader_AuthSchemes(t *testing.T) {
+	data := `{"status": "ok"}`
+	var tokensIssued int32
+	var expireTokens int32
+
+	r := http.NewServeMux()
+	r.HandleFunc("/bearer/12345", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		indexHandler(w, r)
+	})
+	r.HandleFunc("/digest/12345", digestHandler(indexHandler, "admin", "$CrazyUnforgettablePassword?", "test", "dcd98b7102dd2f0e8b11d0f600bfb0c093"))
+	r.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok || clientId != "client" || clientSecret != "secret" || r.FormValue("grant_type") != "client_credentials" {
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(&tokensIssued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token%d","token_type":"bearer","expires_in":3600}`, n)
+	})
+	r.HandleFunc("/oauth2/12345", func(w http.ResponseWriter, r *http.Request) {
+		expected := fmt.Sprintf("Bearer token%d", atomic.LoadInt32(&tokensIssued))
+		if atomic.LoadInt32(&expireTokens) == 1 || r.Header.Get("Authorization") != expected {
+			atomic.StoreInt32(&expireTokens, 0)
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		indexHandler(w, r)
+	})
+	r.HandleFunc("/apikey/12345", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" && r.URL.Query().Get("api_key") != "key" {
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		indexHandler(w, r)
+	})
+	r.HandleFunc("/hmac/12345", func(w http.ResponseWriter, r *http.Request) {
+		bodyHash := sha256.Sum256(nil)
+		mac := hmac.New(sha256.New, []byte("hmac-secret"))
+		mac.Write([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + r.Header.Get("Date") + "\n" + hex.EncodeToString(bodyHash[:])))
+		expected := fmt.Sprintf(`HMAC-SHA256 keyId="key1",signature="%s"`, base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		if r.Header.Get("Authorization") != expected {
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		indexHandler(w, r)
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	httpDownloader := &HttpDownloader{}
+	download := func(urlPath string, credentials *models.Credentials) (*models.RemoteFileContent, error) {
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: ts.URL + urlPath, Credentials: credentials, Timeout: 3 * time.Minute})
+		return httpDownloader.Download(remoteFile)
+	}
+	requireData := func(t *testing.T, result *models.RemoteFileContent, err error) {
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		blobBytes, err := ioutil.ReadAll(result.Blob)
+		require.Nil(t, err)
+		require.Equal(t, data, string(blobBytes))
+		require.Nil(t, result.Blob.Close())
+	}
+
+	t.Run("Http_DownloadWithBearerToken_ReturnsFile", func(t *testing.T) {
+		result, err := download("/bearer/12345", &models.Credentials{HttpAuth: &models.HttpAuth{
+			Type: models.HttpAuthBearer, Token: "secret-token"}})
+		requireData(t, result, err)
+	})
+	t.Run("Http_DownloadWithWrongBearerToken_ReturnsError", func(t *testing.T) {
+		result, err := download("/bearer/12345", &models.Credentials{HttpAuth: &models.HttpAuth{
+			Type: models.HttpAuthBearer, Token: "wrong"}})
+		require.NotNil(t, err)
+		require.Nil(t, result)
+	})
+	t.Run("Http_DownloadWithDigest_ReturnsFile", func(t *testing.T) {
+		result, err := download("/digest/12345", &models.Credentials{User: "admin", Password: "$CrazyUnforgettablePassword?",
+			HttpAuth: &models.HttpAuth{Type: models.HttpAuthDigest}})
+		requireData(t, result, err)
+	})
+	t.Run("Http_DownloadWithWrongDigestPassword_ReturnsError", func(t *testing.T) {
+		result, err := download("/digest/12345", &models.Credentials{User: "admin", Password: "wrong",
+			HttpAuth: &models.HttpAuth{Type: models.HttpAuthDigest}})
+		require.NotNil(t, err)
+		require.Nil(t, result)
+	})
+	t.Run("Http_DownloadWithOAuth2_CachesAndRefreshesToken", func(t *testing.T) {
+		credentials := &models.Credentials{HttpAuth: &models.HttpAuth{Type: models.HttpAuthOAuth2,
+			OAuth2: &models.OAuth2ClientCredentials{TokenUrl: ts.URL + "/token", ClientId: "client", ClientSecret: "secret"}}}
+
+		result, err := download("/oauth2/12345", credentials)
+		requireData(t, result, err)
+		result, err = download("/oauth2/12345", credentials)
+		requireData(t, result, err)
+		require.Equal(t, int32(1), atomic.LoadInt32(&tokensIssued), "token must be cached")
+
+		atomic.StoreInt32(&expireTokens, 1)
+		result, err = download("/oauth2/12345", credentials)
+		requireData(t, result, err)
+		require.Equal(t, int32(2), atomic.LoadInt32(&tokensIssued), "token must be refreshed after 401")
+	})
+	t.Run("Http_DownloadWithOAuth2WrongSecret_ReturnsError", func(t *testing.T) {
+		result, err := download("/oauth2/12345", &models.Credentials{HttpAuth: &models.HttpAuth{Type: models.HttpAuthOAuth2,
+			OAuth2: &models.OAuth2ClientCredentials{TokenUrl: ts.URL + "/token", ClientId: "client", ClientSecret: "wrong"}}})
+		require.NotNil(t, err)
+		require.Contains(t, err.Error(), "oauth2 token request failed")
+		require.Nil(t, result)
+	})
+	t.Run("Http_DownloadWithApiKeyHeader_ReturnsFile", func(t *testing.T) {
+		result, err := download("/apikey/12345", &models.Credentials{HttpAuth: &models.HttpAuth{Type: models.HttpAuthApiKey,
+			ApiKey: &models.ApiKey{Name: "X-Api-Key", Value: "key"}}})
+		requireData(t, result, err)
+	})
+	t.Run("Http_DownloadWithApiKeyQuery_ReturnsFile", func(t *testing.T) {
+		result, err := download("/apikey/12345?a=b", &models.Credentials{HttpAuth: &models.HttpAuth{Type: models.HttpAuthApiKey,
+			ApiKey: &models.ApiKey{Name: "api_key", Value: "key", InQuery: true}}})
+		requireData(t, result, err)
+	})
+	t.Run("Http_DownloadWithHmac_ReturnsFile", func(t *testing.T) {
+		result, err := download("/hmac/12345", &models.Credentials{HttpAuth: &models.HttpAuth{Type: models.HttpAuthHmac,
+			Hmac: &models.HmacSigning{KeyId: "key1", Secret: "hmac-secret"}}})
+		requireData(t, result, err)
+	})
+	t.Run("Http_DownloadWithWrongHmacSecret_ReturnsError", func(t *testing.T) {
+		result, err := download("/hmac/12345", &models.Credentials{HttpAuth: &models.HttpAuth{Type: models.HttpAuthHmac,
+			Hmac: &models.HmacSigning{KeyId: "key1", Secret: "wrong"}}})
+		require.NotNil(t, err)
+		require.Nil(t, result)
+	})
+	t.Run("Http_DownloadWithUnknownAuthType_ReturnsError", func(t *testing.T) {
+		result, err := download("/bearer/12345", &models.Credentials{HttpAuth: &models.HttpAuth{Type: "ntlm"}})
+		require.NotNil(t, err)
+		require.Nil(t, result)
+	})
+}
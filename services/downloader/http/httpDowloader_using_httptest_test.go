@@ -3,11 +3,14 @@ package http
 import (
 	"crypto/sha256"
 	"crypto/subtle"
+	"crypto/tls"
+	"encoding/pem"
 	"fmt"
 	"github.com/goodsru/go-universal-network-adapter/models"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"os"
 	"testing"
@@ -54,7 +57,7 @@ func Test_HttpDownloader_UsingHttpTest(t *testing.T) {
 	defer ts.Close()
 	t.Run("Http_Download_ReturnsFileOverHTTPAndNoError", func(t *testing.T) {
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: ts.URL + "/12345", Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		result, err := httpDownloader.download(client, remoteFile)
 		require.Nil(t, err)
 		require.NoError(t, err, fmt.Sprintf("err == %v, ожидается - nil", err))
@@ -69,7 +72,7 @@ func Test_HttpDownloader_UsingHttpTest(t *testing.T) {
 			User:     `admin`,
 			Password: `$CrazyUnforgettablePassword?`,
 		}, Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		result, err := httpDownloader.download(client, remoteFile)
 		require.Nil(t, err)
 		require.NoError(t, err, fmt.Sprintf("err == %v, ожидается - nil", err))
@@ -85,7 +88,7 @@ func Test_HttpDownloader_UsingHttpTest(t *testing.T) {
 			User:     `admin`,
 			Password: `incorrectPass`,
 		}, Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		result, err := httpDownloader.download(client, remoteFile)
 		require.NotNil(t, err, "Ожидается 401")
 		require.Nil(t, result, "Ожидается пустой результат")
@@ -93,9 +96,244 @@ func Test_HttpDownloader_UsingHttpTest(t *testing.T) {
 
 	t.Run("Http_DownloadNonExistingFile_ReturnsError", func(t *testing.T) {
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: ts.URL + "/noFile", Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		result, err := httpDownloader.download(client, remoteFile)
 		require.NotNil(t, err, "Ожидается 404")
 		require.Nil(t, result, "Ожидается пустой результат")
 	})
 }
+
+func Test_HttpDownloader_TLSOptionsUsingHttpTest(t *testing.T) {
+	httpDownloader := &HttpDownloader{}
+	data := `{"status": "ok"}`
+
+	ts := httptest.NewTLSServer(handlers())
+	defer ts.Close()
+
+	caFile, err := ioutil.TempFile("", "ca.*.pem")
+	require.Nil(t, err)
+	defer os.Remove(caFile.Name())
+	require.Nil(t, pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}))
+	require.Nil(t, caFile.Close())
+
+	download := func(options *models.TLSOptions) (*models.RemoteFileContent, error) {
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: ts.URL + "/12345", Credentials: &models.Credentials{
+			TLSOptions: options,
+		}, Timeout: 3 * time.Minute})
+		return httpDownloader.Download(remoteFile)
+	}
+
+	t.Run("Https_DownloadWithCABundle_ReturnsFileAndNoError", func(t *testing.T) {
+		result, err := download(&models.TLSOptions{CABundlePath: caFile.Name()})
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		blobBytes, err := ioutil.ReadAll(result.Blob)
+		require.Equal(t, data, string(blobBytes))
+	})
+	t.Run("Https_DownloadWithMatchingPin_ReturnsFileAndNoError", func(t *testing.T) {
+		result, err := download(&models.TLSOptions{
+			CABundlePath: caFile.Name(),
+			SPKIPins:     []string{"sha256/" + models.SPKIPin(ts.Certificate())},
+		})
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.NotNil(t, result)
+	})
+	t.Run("Https_DownloadWithInsecureSkipVerify_ReturnsFileAndNoError", func(t *testing.T) {
+		result, err := download(&models.TLSOptions{InsecureSkipVerify: true})
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.NotNil(t, result)
+	})
+	//error tests
+	t.Run("Https_DownloadWithoutCABundle_ReturnsError", func(t *testing.T) {
+		result, err := download(&models.TLSOptions{})
+		require.NotNil(t, err, "Expect x509 unknown authority error")
+		require.Nil(t, result)
+	})
+	t.Run("Https_DownloadWithWrongPin_ReturnsError", func(t *testing.T) {
+		result, err := download(&models.TLSOptions{
+			InsecureSkipVerify: true,
+			SPKIPins:           []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+		})
+		require.NotNil(t, err)
+		require.Contains(t, err.Error(), "SPKI pins")
+		require.Nil(t, result)
+	})
+	t.Run("Https_GetResumedSessionWithWrongPin_ReturnsError", func(t *testing.T) {
+		cache := tls.NewLRUClientSessionCache(1)
+		get := func(pins []string) (*http.Response, error) {
+			config, err := (&models.TLSOptions{CABundlePath: caFile.Name(), SPKIPins: pins}).BuildTLSConfig(
+				&tls.Config{ClientSessionCache: cache})
+			require.Nil(t, err)
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: config, DisableKeepAlives: true}}
+			resp, err := client.Get(ts.URL + "/12345")
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			_, err = ioutil.ReadAll(resp.Body)
+			return resp, err
+		}
+		pin := models.SPKIPin(ts.Certificate())
+
+		resp, err := get([]string{pin})
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.False(t, resp.TLS.DidResume)
+		resp, err = get([]string{pin})
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.True(t, resp.TLS.DidResume, "expected resumed session")
+
+		resp, err = get([]string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="})
+		require.NotNil(t, err)
+		require.Contains(t, err.Error(), "SPKI pins")
+		require.Nil(t, resp)
+	})
+	t.Run("Https_DownloadWithMissingCABundle_ReturnsError", func(t *testing.T) {
+		result, err := download(&models.TLSOptions{CABundlePath: caFile.Name() + ".missing"})
+		require.NotNil(t, err)
+		require.Nil(t, result)
+	})
+}
+
+func Test_HttpDownloader_RequestOptionsUsingHttpTest(t *testing.T) {
+	httpDownloader := &HttpDownloader{}
+	data := "id;name\n1;test\n"
+
+	r := http.NewServeMux()
+	r.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != "POST" || string(body) != `{"login":"admin"}` {
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "42", Path: "/"})
+	})
+	r.HandleFunc("/export", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "42" {
+			http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != "POST" || string(body) != `{"format":"csv"}` || r.Header.Get("X-Export") != "full" ||
+			r.Header.Get("Content-Type") != "application/json" || r.Header.Get("User-Agent") != "una-test" {
+			http.Error(w, "Bad request.", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		_, _ = w.Write([]byte(data))
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.Nil(t, err)
+	exportOptions := &models.HttpRequestOptions{
+		Method:    "POST",
+		Headers:   http.Header{"X-Export": {"full"}, "Content-Type": {"application/json"}},
+		Body:      []byte(`{"format":"csv"}`),
+		CookieJar: jar,
+		UserAgent: "una-test",
+	}
+
+	t.Run("Http_DownloadPostWithoutSession_ReturnsError", func(t *testing.T) {
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: ts.URL + "/export", Timeout: 3 * time.Minute, HttpRequest: exportOptions})
+		result, err := httpDownloader.Download(remoteFile)
+		require.NotNil(t, err, "Expect 401")
+		require.Nil(t, result)
+	})
+	t.Run("Http_DownloadPostWithSessionCookie_ReturnsFileAndNoError", func(t *testing.T) {
+		login, _ := models.NewRemoteFile(&models.Destination{Url: ts.URL + "/login", Timeout: 3 * time.Minute, HttpRequest: &models.HttpRequestOptions{
+			Method: "POST", Body: []byte(`{"login":"admin"}`), CookieJar: jar,
+		}})
+		_, err := httpDownloader.Download(login)
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+
+		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: ts.URL + "/export", Timeout: 3 * time.Minute, HttpRequest: exportOptions})
+		result, err := httpDownloader.Download(remoteFile)
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		blobBytes, err := ioutil.ReadAll(result.Blob)
+		require.Equal(t, data, string(blobBytes))
+	})
+	t.Run("Http_StatPostWithSessionCookie_ReturnsSize", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: ts.URL + "/export", Timeout: 3 * time.Minute, HttpRequest: exportOptions})
+		remoteFile, err := httpDownloader.Stat(parsedDest)
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.Equal(t, int64(len(data)), remoteFile.Size)
+	})
+}
+
+func Test_HttpDownloader_StatMetadataUsingHttpTest(t *testing.T) {
+	data := `{"status": "ok"}`
+	lastmod := time.Date(2020, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	r := http.NewServeMux()
+	//chunked response without content-length, size is known from content-range only
+	r.HandleFunc("/chunked/report", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Last-Modified", lastmod.Format(http.TimeFormat))
+		w.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''%D0%BE%D1%82%D1%87%D0%B5%D1%82.json`)
+		if r.Method == "GET" && r.Header.Get("Range") == "bytes=0-0" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-0/%d", len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(data[:1]))
+			return
+		}
+		w.Header().Set("Transfer-Encoding", "chunked")
+		w.(http.Flusher).Flush()
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(data))
+		}
+	})
+	r.HandleFunc("/nohead/report.json", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-0/%d", len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte(data[:1]))
+	})
+	r.HandleFunc("/stream/report.json", func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(data))
+		}
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	httpDownloader := &HttpDownloader{}
+	stat := func(urlPath string) (*models.RemoteFile, error) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: ts.URL + urlPath, Timeout: 3 * time.Minute})
+		return httpDownloader.Stat(parsedDest)
+	}
+
+	t.Run("Http_StatWithoutContentLength_ReturnsSizeFromContentRange", func(t *testing.T) {
+		remoteFile, err := stat("/chunked/report")
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.Equal(t, int64(len(data)), remoteFile.Size)
+		require.Equal(t, "отчет.json", remoteFile.Name)
+		require.Equal(t, "/chunked/", remoteFile.Path)
+		require.True(t, lastmod.Equal(remoteFile.Lastmod), fmt.Sprintf("lastmod %v, expected %v", remoteFile.Lastmod, lastmod))
+		require.Equal(t, `"abc"`, remoteFile.ETag)
+		require.Equal(t, "application/json", remoteFile.ContentType)
+		require.Equal(t, lastmod.Format(http.TimeFormat), remoteFile.Metadata.Facts["Last-Modified"])
+	})
+	t.Run("Http_StatHeadNotAllowed_ReturnsSizeFromRangedGet", func(t *testing.T) {
+		remoteFile, err := stat("/nohead/report.json")
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.Equal(t, int64(len(data)), remoteFile.Size)
+		require.Equal(t, "report.json", remoteFile.Name)
+	})
+	t.Run("Http_StatWithoutAnySize_ReturnsUnknownSize", func(t *testing.T) {
+		remoteFile, err := stat("/stream/report.json")
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.Equal(t, models.UnknownSize, remoteFile.Size)
+		require.Equal(t, "report.json", remoteFile.Name)
+	})
+	t.Run("Http_StatNonExistingFile_ReturnsError", func(t *testing.T) {
+		remoteFile, err := stat("/noFile")
+		require.NotNil(t, err, "Expect 404")
+		require.Nil(t, remoteFile)
+	})
+}
@@ -2,34 +2,53 @@
 package http
 
 import (
+	"bytes"
 	"errors"
-	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
 
 	"github.com/goodsru/go-universal-network-adapter/models"
 )
 
 type HttpDownloader struct {
+	//Custom directory listing parsers. Tried by Browse before the default ones
+	ListingParsers []ListingParser
+	//OAuth2 tokens received with client credentials grant
+	tokens oauth2TokenCache
 }
 
-//Service method,that makes a HEAD request to remote server to get file size info
+//Service method,that makes a HEAD request to remote server to get file size and metadata. If size is not
+//known from HEAD, asks for the first byte and takes size from Content-Range, otherwise size is models.UnknownSize
 func (httpDownloader *HttpDownloader) Stat(destination *models.ParsedDestination) (*models.RemoteFile, error) {
-	httpClient := httpDownloader.getClient(destination)
+	httpClient, err := httpDownloader.getClient(destination)
+	if err != nil {
+		return nil, err
+	}
 	return httpDownloader.stat(httpClient, destination)
 }
 
-//Not possible to implement this functionality thru HTTP protocol
+//Browse a directory listing page (Apache/nginx/lighttpd/IIS autoindex, nginx json autoindex,
+//S3 xml listing or format of custom ListingParsers)
 func (httpDownloader *HttpDownloader) Browse(destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
-	return nil, fmt.Errorf("not implemented")
+	httpClient, err := httpDownloader.getClient(destination)
+	if err != nil {
+		return nil, err
+	}
+	return httpDownloader.browse(httpClient, destination)
 }
 
 //Method allows download file from remote server, store it in temporary directory and
 //return back RemoteFileContent with io.ReadCloser for further manipulations
 func (httpDownloader *HttpDownloader) Download(remoteFile *models.RemoteFile) (*models.RemoteFileContent, error) {
-	httpClient := httpDownloader.getClient(remoteFile.ParsedDestination)
+	httpClient, err := httpDownloader.getClient(remoteFile.ParsedDestination)
+	if err != nil {
+		return nil, err
+	}
 	return httpDownloader.download(httpClient, remoteFile)
 }
 
@@ -43,19 +62,11 @@ func (httpDownloader *HttpDownloader) download(client *http.Client, remoteFile *
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest("GET", remoteFile.ParsedDestination.Url, nil)
+	req, err := httpDownloader.newRequest("", remoteFile.ParsedDestination)
 	if err != nil {
 		return nil, err
 	}
-	destination := remoteFile.ParsedDestination
-	user := destination.GetUser()
-	password := destination.GetPassword()
-
-	if password != "" {
-		req.SetBasicAuth(user, password)
-	}
-
-	resp, err := client.Do(req)
+	resp, err := httpDownloader.do(client, req, remoteFile.ParsedDestination)
 	if err != nil {
 		return nil, err
 	}
@@ -75,44 +86,176 @@ func (httpDownloader *HttpDownloader) download(client *http.Client, remoteFile *
 
 }
 
-//Return basic golang http Client with custom timeout from user request
-func (httpDownloader *HttpDownloader) getClient(destination *models.ParsedDestination) *http.Client { //IHttpClient
+func (httpDownloader *HttpDownloader) browse(client *http.Client, destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
+	req, err := httpDownloader.newRequest("", destination)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpDownloader.do(client, req, destination)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return httpDownloader.parseListing(destination, resp.Header.Get("Content-Type"), body)
+}
+
+//Return basic golang http Client with custom timeout and TLS settings from user request
+func (httpDownloader *HttpDownloader) getClient(destination *models.ParsedDestination) (*http.Client, error) { //IHttpClient
 	client := &http.Client{
 		Timeout: destination.Timeout,
 	}
-	return client
+	tlsConfig, err := destination.GetTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}
+	}
+	if destination.HttpRequest != nil {
+		client.Jar = destination.HttpRequest.CookieJar
+	}
+	return client, nil
+}
+
+//Creates request with method, headers and body from destination. Empty method means
+//the one from destination or GET
+func (httpDownloader *HttpDownloader) newRequest(method string, destination *models.ParsedDestination) (*http.Request, error) {
+	options := destination.HttpRequest
+	if options == nil {
+		options = &models.HttpRequestOptions{}
+	}
+	if method == "" {
+		method = options.Method
+	}
+	if method == "" {
+		method = "GET"
+	}
+
+	var body io.Reader
+	if len(options.Body) > 0 && method != "HEAD" {
+		body = bytes.NewReader(options.Body)
+	}
+	req, err := http.NewRequest(method, destination.Url, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range options.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if options.UserAgent != "" {
+		req.Header.Set("User-Agent", options.UserAgent)
+	}
+	return req, nil
 }
 
 func (httpDownloader *HttpDownloader) stat(client *http.Client, destination *models.ParsedDestination) (*models.RemoteFile, error) {
-	req, err := http.NewRequest("HEAD", destination.Url, nil)
+	method := "HEAD"
+	if options := destination.HttpRequest; options != nil && options.Method != "" && options.Method != "GET" {
+		method = options.Method
+	}
+	resp, err := httpDownloader.statRequest(client, destination, method, false)
 	if err != nil {
 		return nil, err
 	}
-	user := destination.GetUser()
-	password := destination.GetPassword()
+	//some servers do not allow HEAD
+	if method == "HEAD" && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		if resp, err = httpDownloader.statRequest(client, destination, "GET", true); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, errors.New(resp.Status)
+	}
+
+	size := responseSize(resp)
+	//chunked responses have no content-length, ask for the first byte to get size from content-range
+	if size == models.UnknownSize && method == "HEAD" && resp.Request.Method == "HEAD" {
+		rangeResp, err := httpDownloader.statRequest(client, destination, "GET", true)
+		if err != nil {
+			return nil, err
+		}
+		if rangeResp.StatusCode == http.StatusOK || rangeResp.StatusCode == http.StatusPartialContent {
+			size = responseSize(rangeResp)
+		}
+	}
 
-	if password != "" {
-		req.SetBasicAuth(user, password)
+	dir, name := path.Split(destination.GetPath())
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = path.Base(params["filename"])
 	}
+	lastmod, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
 
-	resp, err := client.Do(req)
+	return &models.RemoteFile{
+		Name:              name,
+		Path:              dir,
+		Size:              size,
+		Lastmod:           lastmod,
+		ETag:              resp.Header.Get("ETag"),
+		ContentType:       resp.Header.Get("Content-Type"),
+		Metadata:          &models.FileMetadata{Facts: headerFacts(resp.Header)},
+		ParsedDestination: destination,
+	}, nil
+}
+
+//Response headers with their first values. Cookies are not metadata of file and are skipped
+func headerFacts(header http.Header) map[string]string {
+	facts := make(map[string]string, len(header))
+	for name, values := range header {
+		if name != "Set-Cookie" && len(values) > 0 {
+			facts[name] = values[0]
+		}
+	}
+	return facts
+}
+
+//Sends stat request. Response body is not read and already closed. If firstByte is set, asks for the first
+//byte only with Range header
+func (httpDownloader *HttpDownloader) statRequest(client *http.Client, destination *models.ParsedDestination, method string, firstByte bool) (*http.Response, error) {
+	req, err := httpDownloader.newRequest(method, destination)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(resp.Status)
+	if firstByte {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := httpDownloader.do(client, req, destination)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+//Returns full remote file size from content-range of partial response or content-length of full one
+func responseSize(resp *http.Response) int64 {
+	if resp.StatusCode == http.StatusPartialContent {
+		contentRange := resp.Header.Get("Content-Range")
+		if slash := strings.LastIndex(contentRange, "/"); slash >= 0 {
+			if size, err := strconv.ParseInt(contentRange[slash+1:], 10, 64); err == nil {
+				return size
+			}
+		}
+		return models.UnknownSize
 	}
 	fileSizeStr := resp.Header.Get("content-length")
 	if len(fileSizeStr) == 0 {
-		return nil, &models.UnaError{
-			Code:    42,
-			Message: "Не удалось получить размер файла",
-		}
+		return models.UnknownSize
 	}
-	filePath := destination.GetPath()
 	fileSize, err := strconv.ParseInt(fileSizeStr, 10, 64)
 	if err != nil {
-		return nil, errors.New(resp.Status)
+		return models.UnknownSize
 	}
-	return &models.RemoteFile{Path: filePath, Size: fileSize, ParsedDestination: destination}, nil
+	return fileSize
 }
@@ -27,7 +27,7 @@ func Test_HttpDownloader_IntegrationHttps(t *testing.T) {
 	srv := integrationTest.StartWebServer("HTTPS")
 	t.Run("Http_Download_ReturnsFileOverHttpsAndNoError", func(t *testing.T) {
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: "https://localhost:9889/12345", Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		//set root certificate for access without authentication error
 		caCert, err := ioutil.ReadFile("cert/rootCA1Cert.pem")
 		if err != nil {
@@ -54,7 +54,7 @@ func Test_HttpDownloader_IntegrationHttps(t *testing.T) {
 	//error test
 	t.Run("Http_Download_ReturnsFileOverHttpsAndError", func(t *testing.T) {
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: "https://localhost:9889/12345", Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		result, err := httpDownloader.download(client, remoteFile)
 		require.NotNil(t, err, "Expect TLS handshake error from remote error: tls: bad certificate")
 		require.Nil(t, result, "Expect empty result")
@@ -90,7 +90,7 @@ func Test_HttpDownloader_IntegrationHttpsCertVerify(t *testing.T) {
 		}
 		tlsConfig.BuildNameToCertificate()
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: "https://localhost:9890/12345", Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
 		result, err := httpDownloader.download(client, remoteFile)
 		require.Nil(t, err, fmt.Sprintf("err == %v, Expect - nil", err))
@@ -124,7 +124,7 @@ func Test_HttpDownloader_IntegrationHttpsCertVerify(t *testing.T) {
 		}
 		tlsConfig.BuildNameToCertificate()
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: "https://localhost:9890/12345", Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
 		result, err := httpDownloader.download(client, remoteFile)
 		require.NotNil(t, err, "Expect Err:x509.UnknownAuthorityError")
@@ -143,7 +143,7 @@ func Test_HttpDownloader_IntegrationHttp(t *testing.T) {
 	srv := integrationTest.StartWebServer("HTTP")
 	t.Run("Http_Download_ReturnsFileOverHTTPAndNoError", func(t *testing.T) {
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: "http://localhost:9888/12345", Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		result, err := httpDownloader.download(client, remoteFile)
 		require.Nil(t, err)
 		require.NoError(t, err, fmt.Sprintf("err == %v, Expect - nil", err))
@@ -158,7 +158,7 @@ func Test_HttpDownloader_IntegrationHttp(t *testing.T) {
 			User:     `admin`,
 			Password: `$CrazyUnforgettablePassword?`,
 		}, Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		result, err := httpDownloader.download(client, remoteFile)
 		require.Nil(t, err, fmt.Sprintf("err == %v, Expect - nil", err))
 		require.Equal(t, fileName, result.Name, fmt.Sprintf("Received file name %v, expected - %v", result.Name, fileName))
@@ -173,7 +173,7 @@ func Test_HttpDownloader_IntegrationHttp(t *testing.T) {
 			User:     `admin`,
 			Password: `incorrectPass`,
 		}, Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		result, err := httpDownloader.download(client, remoteFile)
 		require.NotNil(t, err, "Expect 401")
 		require.Nil(t, result, "Expect empty result")
@@ -181,7 +181,7 @@ func Test_HttpDownloader_IntegrationHttp(t *testing.T) {
 
 	t.Run("Http_DownloadNonExistingFile_ReturnsError", func(t *testing.T) {
 		remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: "http://localhost:9888/noFile", Timeout: 3 * time.Minute})
-		client := httpDownloader.getClient(remoteFile.ParsedDestination)
+		client, _ := httpDownloader.getClient(remoteFile.ParsedDestination)
 		result, err := httpDownloader.download(client, remoteFile)
 		require.NotNil(t, err, "Expect 404")
 		require.Nil(t, result, "Expect empty result")
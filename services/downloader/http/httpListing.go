@@ -0,0 +1,288 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Parser of remote directory listing, returned by HTTP server
+type ListingParser interface {
+	//Returns true, if parser recognizes the listing format
+	CanParse(contentType string, body []byte) bool
+	//Parses listing into remote files. Each file gets own ParsedDestination, so it can be downloaded
+	Parse(destination *models.ParsedDestination, body []byte) ([]*models.RemoteFile, error)
+}
+
+//Parsers used by Browse after HttpDownloader.ListingParsers
+var defaultListingParsers = []ListingParser{
+	&JsonAutoindexParser{},
+	&S3XmlListingParser{},
+	&HtmlListingParser{},
+}
+
+func (httpDownloader *HttpDownloader) parseListing(destination *models.ParsedDestination, contentType string, body []byte) ([]*models.RemoteFile, error) {
+	parsers := append(append([]ListingParser{}, httpDownloader.ListingParsers...), defaultListingParsers...)
+	for _, parser := range parsers {
+		if parser.CanParse(contentType, body) {
+			return parser.Parse(destination, body)
+		}
+	}
+	return nil, fmt.Errorf("unsupported directory listing format: %s", contentType)
+}
+
+//Returns destination of the listing entry. href is resolved against listing url
+func childDestination(destination *models.ParsedDestination, href string) (*models.ParsedDestination, error) {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+	child := *destination
+	child.ParsedUrl = destination.ParsedUrl.ResolveReference(ref)
+	child.Url = child.ParsedUrl.String()
+	return &child, nil
+}
+
+//Creates remote file for listing entry. href is resolved against directory url of destination, so custom
+//ListingParser implementations get downloadable files
+func NewListingEntry(destination *models.ParsedDestination, href string, isDir bool, size int64, lastmod time.Time) (*models.RemoteFile, error) {
+	child, err := childDestination(withDirSlash(destination), href)
+	if err != nil {
+		return nil, err
+	}
+	dir, name := path.Split(strings.TrimSuffix(child.GetPath(), "/"))
+	if isDir {
+		size = 0
+	}
+	return &models.RemoteFile{Name: name, Path: dir, ParsedDestination: child, Size: size, Lastmod: lastmod, IsDir: isDir}, nil
+}
+
+//nginx autoindex_format json
+type JsonAutoindexParser struct{}
+
+func (parser *JsonAutoindexParser) CanParse(contentType string, body []byte) bool {
+	return strings.Contains(contentType, "json") || bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+}
+
+func (parser *JsonAutoindexParser) Parse(destination *models.ParsedDestination, body []byte) ([]*models.RemoteFile, error) {
+	var entries []struct {
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+		Mtime string `json:"mtime"`
+		Size  *int64 `json:"size"`
+	}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, err
+	}
+
+	result := make([]*models.RemoteFile, 0, len(entries))
+	for _, entry := range entries {
+		isDir := entry.Type == "directory"
+		href := "./" + url.PathEscape(entry.Name)
+		if isDir {
+			href += "/"
+		}
+		size := models.UnknownSize
+		if entry.Size != nil {
+			size = *entry.Size
+		}
+		lastmod, _ := time.Parse(time.RFC1123, entry.Mtime)
+		file, err := NewListingEntry(destination, href, isDir, size, lastmod)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, file)
+	}
+	return result, nil
+}
+
+//S3 compatible ListObjects/ListObjectsV2 xml response (public buckets, MinIO, etc.)
+type S3XmlListingParser struct{}
+
+func (parser *S3XmlListingParser) CanParse(contentType string, body []byte) bool {
+	return bytes.Contains(body, []byte("<ListBucketResult"))
+}
+
+func (parser *S3XmlListingParser) Parse(destination *models.ParsedDestination, body []byte) ([]*models.RemoteFile, error) {
+	var listing struct {
+		Prefix   string
+		Contents []struct {
+			Key          string
+			LastModified time.Time
+			Size         int64
+		}
+		CommonPrefixes []struct {
+			Prefix string
+		}
+	}
+	if err := xml.Unmarshal(body, &listing); err != nil {
+		return nil, err
+	}
+
+	//keys are relative to bucket root, which is listing url without query
+	result := make([]*models.RemoteFile, 0, len(listing.Contents)+len(listing.CommonPrefixes))
+	for _, prefix := range listing.CommonPrefixes {
+		file, err := NewListingEntry(destination, escapeKey(prefix.Prefix), true, 0, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, file)
+	}
+	for _, object := range listing.Contents {
+		if strings.HasSuffix(object.Key, "/") && object.Key == listing.Prefix {
+			continue
+		}
+		file, err := NewListingEntry(destination, escapeKey(object.Key), false, object.Size, object.LastModified)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, file)
+	}
+	return result, nil
+}
+
+//Apache, nginx, lighttpd and IIS html directory listings.
+//Each row (line, <br> or table row) with a single link is an entry, date and size are searched
+//in the rest of the row
+type HtmlListingParser struct{}
+
+var (
+	htmlRowSplitter = regexp.MustCompile(`(?i)<br\s*/?>|</tr>|\n`)
+	htmlAnchor      = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	htmlTag         = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlSize        = regexp.MustCompile(`(?i)(?:^|\s)(\d+(?:\.\d+)?)\s?([KMGT]?)i?B?(?:\s|$)`)
+	htmlDirMarker   = regexp.MustCompile(`(?i)<dir>`)
+)
+
+//Date formats of supported servers. Regexp and time layout pairs
+var htmlDateFormats = []struct {
+	pattern *regexp.Regexp
+	layouts []string
+}{
+	//Apache: 2020-01-31 10:00
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}(?::\d{2})?`), []string{"2006-01-02 15:04:05", "2006-01-02 15:04"}},
+	//nginx, old Apache: 31-Jan-2020 10:00
+	{regexp.MustCompile(`\d{2}-[A-Za-z]{3}-\d{4} \d{2}:\d{2}(?::\d{2})?`), []string{"02-Jan-2006 15:04:05", "02-Jan-2006 15:04"}},
+	//lighttpd: 2020-Jan-31 10:00:00
+	{regexp.MustCompile(`\d{4}-[A-Za-z]{3}-\d{2} \d{2}:\d{2}(?::\d{2})?`), []string{"2006-Jan-02 15:04:05", "2006-Jan-02 15:04"}},
+	//IIS: 1/31/2020 10:00 AM
+	{regexp.MustCompile(`\d{1,2}/\d{1,2}/\d{4}\s+\d{1,2}:\d{2}\s*[AP]M`), []string{"1/2/2006 3:04 PM"}},
+	//IIS long format: Friday, January 31, 2020 10:00 AM
+	{regexp.MustCompile(`[A-Za-z]+, [A-Za-z]+ \d{1,2}, \d{4}\s+\d{1,2}:\d{2}\s*[AP]M`), []string{"Monday, January 2, 2006 3:04 PM"}},
+}
+
+func (parser *HtmlListingParser) CanParse(contentType string, body []byte) bool {
+	return strings.Contains(contentType, "html") || htmlAnchor.Match(body)
+}
+
+func (parser *HtmlListingParser) Parse(destination *models.ParsedDestination, body []byte) ([]*models.RemoteFile, error) {
+	base := withDirSlash(destination)
+	result := make([]*models.RemoteFile, 0)
+	seen := make(map[string]bool)
+
+	for _, row := range htmlRowSplitter.Split(string(body), -1) {
+		anchors := htmlAnchor.FindAllStringSubmatchIndex(row, -1)
+		if len(anchors) != 1 {
+			continue
+		}
+		href := html.UnescapeString(row[anchors[0][2]:anchors[0][3]])
+		child, ok := parser.listingChild(base, href)
+		if !ok || seen[child] {
+			continue
+		}
+		seen[child] = true
+
+		rest := html.UnescapeString(htmlTag.ReplaceAllString(row[:anchors[0][0]]+" "+row[anchors[0][1]:], " "))
+		isDir := strings.HasSuffix(href, "/") || htmlDirMarker.MatchString(rest)
+
+		lastmod := time.Time{}
+		for _, format := range htmlDateFormats {
+			if match := format.pattern.FindString(rest); match != "" {
+				normalized := strings.Join(strings.Fields(match), " ")
+				for _, layout := range format.layouts {
+					if parsed, err := time.Parse(layout, normalized); err == nil {
+						lastmod = parsed
+						break
+					}
+				}
+				rest = strings.Replace(rest, match, " ", 1)
+				break
+			}
+		}
+
+		size := models.UnknownSize
+		if match := htmlSize.FindStringSubmatch(rest); match != nil {
+			size = parseHumanSize(match[1], match[2])
+		}
+
+		file, err := NewListingEntry(destination, href, isDir, size, lastmod)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, file)
+	}
+	return result, nil
+}
+
+//Returns resolved path of link, if it points to the direct child of the listing
+func (parser *HtmlListingParser) listingChild(base *models.ParsedDestination, href string) (string, bool) {
+	if href == "" || strings.HasPrefix(href, "?") || strings.HasPrefix(href, "#") {
+		return "", false
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	resolved := base.ParsedUrl.ResolveReference(ref)
+	if resolved.Host != base.ParsedUrl.Host || resolved.RawQuery != "" {
+		return "", false
+	}
+	dir, name := path.Split(strings.TrimSuffix(resolved.Path, "/"))
+	if name == "" || dir != base.ParsedUrl.Path {
+		return "", false
+	}
+	return resolved.Path, true
+}
+
+//Converts sizes like 1.2K to bytes
+func parseHumanSize(number, unit string) int64 {
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return models.UnknownSize
+	}
+	multiplier := map[string]float64{"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}[strings.ToUpper(unit)]
+	return int64(value * multiplier)
+}
+
+//Listing entries are relative to directory url, which must end with slash
+func withDirSlash(destination *models.ParsedDestination) *models.ParsedDestination {
+	dir := *destination
+	parsedUrl := *destination.ParsedUrl
+	parsedUrl.RawQuery = ""
+	if !strings.HasSuffix(parsedUrl.Path, "/") {
+		parsedUrl.Path += "/"
+		parsedUrl.RawPath = ""
+	}
+	dir.ParsedUrl = &parsedUrl
+	dir.Url = parsedUrl.String()
+	return &dir
+}
+
+//Escapes S3 key to relative url, keeping slashes
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "./" + strings.Join(segments, "/")
+}
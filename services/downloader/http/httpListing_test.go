@@ -0,0 +1,186 @@
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"github.com/stretchr/testify/require"
+)
+
+const apacheListing = `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN">
+<html><head><title>Index of /files</title></head><body>
+<h1>Index of /files</h1>
+<table>
+<tr><th valign="top"><img src="/icons/blank.gif" alt="[ICO]"></th><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th><th><a href="?C=S;O=A">Size</a></th></tr>
+<tr><th colspan="5"><hr></th></tr>
+<tr><td valign="top"><img src="/icons/back.gif" alt="[PARENTDIR]"></td><td><a href="/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td></tr>
+<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="archive/">archive/</a></td><td align="right">2020-01-30 09:15  </td><td align="right">  - </td></tr>
+<tr><td valign="top"><img src="/icons/text.gif" alt="[TXT]"></td><td><a href="report%201.csv">report 1.csv</a></td><td align="right">2020-01-31 10:00  </td><td align="right">1.5K</td></tr>
+<tr><th colspan="5"><hr></th></tr>
+</table>
+</body></html>`
+
+const nginxListing = `<html>
+<head><title>Index of /files/</title></head>
+<body>
+<h1>Index of /files/</h1><hr><pre><a href="../">../</a>
+<a href="archive/">archive/</a>                                           30-Jan-2020 09:15                   -
+<a href="report%201.csv">report 1.csv</a>                                       31-Jan-2020 10:00                1536
+</pre><hr></body>
+</html>`
+
+const lighttpdListing = `<html><head><title>Index of /files/</title></head><body>
+<h2>Index of /files/</h2>
+<div class="list">
+<table summary="Directory Listing" cellpadding="0" cellspacing="0">
+<thead><tr><th class="n">Name</th><th class="m">Last Modified</th><th class="s">Size</th><th class="t">Type</th></tr></thead>
+<tbody>
+<tr class="d"><td class="n"><a href="../">Parent Directory</a>/</td><td class="m">&nbsp;</td><td class="s">- &nbsp;</td><td class="t">Directory</td></tr>
+<tr class="d"><td class="n"><a href="archive/">archive</a>/</td><td class="m">2020-Jan-30 09:15:00</td><td class="s">- &nbsp;</td><td class="t">Directory</td></tr>
+<tr><td class="n"><a href="report%201.csv">report 1.csv</a></td><td class="m">2020-Jan-31 10:00:00</td><td class="s">1.5K</td><td class="t">text/csv</td></tr>
+</tbody>
+</table>
+</div>
+</body></html>`
+
+const iisListing = `<html><head><title>localhost - /files/</title></head><body><H1>localhost - /files/</H1><hr>
+
+<pre><A HREF="/">[To Parent Directory]</A><br><br>  1/30/2020  9:15 AM        &lt;dir&gt; <A HREF="/iis/files/archive/">archive</A><br>  1/31/2020 10:00 AM         1536 <A HREF="/iis/files/report%201.csv">report 1.csv</A><br></pre><hr></body></html>`
+
+const nginxJsonListing = `[
+{ "name":"archive", "type":"directory", "mtime":"Thu, 30 Jan 2020 09:15:00 GMT" },
+{ "name":"report 1.csv", "type":"file", "mtime":"Fri, 31 Jan 2020 10:00:00 GMT", "size":1536 }
+]`
+
+const s3Listing = `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+<Name>bucket</Name><Prefix>files/</Prefix><Delimiter>/</Delimiter><IsTruncated>false</IsTruncated>
+<Contents><Key>files/</Key><LastModified>2020-01-30T09:15:00.000Z</LastModified><Size>0</Size></Contents>
+<Contents><Key>files/report 1.csv</Key><LastModified>2020-01-31T10:00:00.000Z</LastModified><Size>1536</Size></Contents>
+<CommonPrefixes><Prefix>files/archive/</Prefix></CommonPrefixes>
+</ListBucketResult>`
+
+//Custom listing format: one file name per line
+type plainTextListingParser struct{}
+
+func (parser *plainTextListingParser) CanParse(contentType string, body []byte) bool {
+	return strings.HasPrefix(contentType, "text/plain")
+}
+
+func (parser *plainTextListingParser) Parse(destination *models.ParsedDestination, body []byte) ([]*models.RemoteFile, error) {
+	result := make([]*models.RemoteFile, 0)
+	for _, name := range strings.Fields(string(body)) {
+		file, err := NewListingEntry(destination, "./"+name, false, models.UnknownSize, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, file)
+	}
+	return result, nil
+}
+
+func Test_HttpDownloader_Browse(t *testing.T) {
+	listings := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"apache":   {"text/html;charset=UTF-8", apacheListing},
+		"nginx":    {"text/html", nginxListing},
+		"lighttpd": {"text/html", lighttpdListing},
+		"iis":      {"text/html", iisListing},
+		"json":     {"application/json", nginxJsonListing},
+	}
+
+	r := http.NewServeMux()
+	for name, listing := range listings {
+		listing := listing
+		r.HandleFunc("/"+name+"/files/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", listing.contentType)
+			_, _ = w.Write([]byte(listing.body))
+		})
+	}
+	r.HandleFunc("/iis/files/report 1.csv", indexHandler)
+	r.HandleFunc("/bucket", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(s3Listing))
+	})
+	r.HandleFunc("/bucket/files/report 1.csv", indexHandler)
+	r.HandleFunc("/plain/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("a.txt\nb.txt\n"))
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	httpDownloader := &HttpDownloader{}
+	expectedLastmod := time.Date(2020, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	for name := range listings {
+		name := name
+		t.Run("Http_Browse_"+name+"_ReturnsFilesAndDirs", func(t *testing.T) {
+			parsedDest, _ := models.ParseDestination(&models.Destination{Url: ts.URL + "/" + name + "/files/", Timeout: 3 * time.Minute})
+			list, err := httpDownloader.Browse(parsedDest)
+			require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+			require.Len(t, list, 2)
+
+			require.Equal(t, "archive", list[0].Name)
+			require.True(t, list[0].IsDir)
+
+			file := list[1]
+			require.Equal(t, "report 1.csv", file.Name)
+			require.Equal(t, "/"+name+"/files/", file.Path)
+			require.False(t, file.IsDir)
+			require.Equal(t, int64(1536), file.Size)
+			require.True(t, expectedLastmod.Equal(file.Lastmod), fmt.Sprintf("lastmod %v, expected %v", file.Lastmod, expectedLastmod))
+		})
+	}
+
+	t.Run("Http_BrowseS3Xml_ReturnsDownloadableFiles", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: ts.URL + "/bucket?prefix=files/&delimiter=/", Timeout: 3 * time.Minute})
+		list, err := httpDownloader.Browse(parsedDest)
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.Len(t, list, 2)
+		require.Equal(t, "archive", list[0].Name)
+		require.True(t, list[0].IsDir)
+		require.Equal(t, "report 1.csv", list[1].Name)
+		require.Equal(t, "/bucket/files/", list[1].Path)
+		require.Equal(t, int64(1536), list[1].Size)
+		require.True(t, expectedLastmod.Equal(list[1].Lastmod))
+
+		result, err := httpDownloader.Download(list[1])
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		blobBytes, err := ioutil.ReadAll(result.Blob)
+		require.Equal(t, `{"status": "ok"}`, string(blobBytes))
+	})
+
+	t.Run("Http_BrowseWithCustomParser_UsesIt", func(t *testing.T) {
+		downloader := &HttpDownloader{ListingParsers: []ListingParser{&plainTextListingParser{}}}
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: ts.URL + "/plain/", Timeout: 3 * time.Minute})
+		list, err := downloader.Browse(parsedDest)
+		require.Nil(t, err, fmt.Sprintf("err == %v, expected - nil", err))
+		require.Len(t, list, 2)
+		require.Equal(t, "b.txt", list[1].Name)
+		require.Equal(t, ts.URL+"/plain/b.txt", list[1].ParsedDestination.Url)
+	})
+
+	//error tests
+	t.Run("Http_BrowseUnknownFormat_ReturnsError", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: ts.URL + "/plain/", Timeout: 3 * time.Minute})
+		list, err := httpDownloader.Browse(parsedDest)
+		require.NotNil(t, err)
+		require.Contains(t, err.Error(), "unsupported directory listing format")
+		require.Nil(t, list)
+	})
+	t.Run("Http_BrowseNonExistingDir_ReturnsError", func(t *testing.T) {
+		parsedDest, _ := models.ParseDestination(&models.Destination{Url: ts.URL + "/noDir/", Timeout: 3 * time.Minute})
+		list, err := httpDownloader.Browse(parsedDest)
+		require.NotNil(t, err, "Expect 404")
+		require.Nil(t, list)
+	})
+}
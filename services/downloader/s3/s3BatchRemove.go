@@ -0,0 +1,235 @@
+package s3
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Max number of keys in one DeleteObjects request
+const deleteBatchSize = 1000
+
+//Key, which was not deleted by batch delete
+type KeyError struct {
+	Bucket    string
+	Key       string
+	VersionId string
+	Code      string
+	Message   string
+}
+
+//Returned by RemoveFiles and RemovePrefix, when some keys were not deleted. Other keys are deleted. Errors of
+//all buckets are collected together. Err is the first failed request (client setup or DeleteObjects), keys of
+//that request are in unknown state
+type BatchDeleteError struct {
+	Errors []KeyError
+	Err    error
+}
+
+func (e *BatchDeleteError) Error() string {
+	keys := make([]string, len(e.Errors))
+	for i, keyError := range e.Errors {
+		keys[i] = fmt.Sprintf("%s/%s (%s: %s)", keyError.Bucket, keyError.Key, keyError.Code, keyError.Message)
+	}
+	message := fmt.Sprintf("failed to delete %d keys: %s", len(e.Errors), strings.Join(keys, ", "))
+	if e.Err != nil {
+		message = fmt.Sprintf("%s; request failed: %v", message, e.Err)
+	}
+	return message
+}
+
+func (e *BatchDeleteError) Unwrap() error {
+	return e.Err
+}
+
+//Deletes files with DeleteObjects requests, up to 1000 keys per request. Files may belong to different buckets.
+//Files with versionId delete specific versions. Files are batched by bucket and by credentials, timeout and
+//S3Options of their destination. Every batch is processed, even if request of some batch fails, and
+//*BatchDeleteError with keys of all buckets and the first request error is returned
+func (s *S3Downloader) RemoveFiles(files []*models.RemoteFile) error {
+	type bucketBatch struct {
+		destination *models.ParsedDestination
+		bucket      string
+		objects     []*s3.ObjectIdentifier
+	}
+	batches := make([]*bucketBatch, 0)
+	byBucket := make(map[string][]*bucketBatch)
+	for _, file := range files {
+		location, err := file.ParsedDestination.GetS3Location()
+		if err != nil {
+			return err
+		}
+		if location.IsPrefix() {
+			return fmt.Errorf("s3 url has no object key: %s", file.ParsedDestination.Url)
+		}
+		id := location.Endpoint + "|" + location.Bucket
+		var batch *bucketBatch
+		for _, candidate := range byBucket[id] {
+			if sameClient(candidate.destination, file.ParsedDestination) {
+				batch = candidate
+				break
+			}
+		}
+		if batch == nil {
+			batch = &bucketBatch{destination: file.ParsedDestination, bucket: location.Bucket}
+			byBucket[id] = append(byBucket[id], batch)
+			batches = append(batches, batch)
+		}
+		batch.objects = append(batch.objects, &s3.ObjectIdentifier{Key: aws.String(location.Key), VersionId: versionIdParam(location)})
+	}
+
+	var keyErrors []KeyError
+	var requestErr error
+	for _, batch := range batches {
+		client, err := s.getClient(batch.destination)
+		if err != nil {
+			if requestErr == nil {
+				requestErr = err
+			}
+			continue
+		}
+		deleter := &batchDeleter{client: client, bucket: batch.bucket}
+		for _, object := range batch.objects {
+			if err = deleter.add(object); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = deleter.flush()
+		}
+		if err != nil && requestErr == nil {
+			requestErr = err
+		}
+		keyErrors = append(keyErrors, deleter.errors...)
+	}
+	if len(keyErrors) > 0 || requestErr != nil {
+		return &BatchDeleteError{Errors: keyErrors, Err: requestErr}
+	}
+	return nil
+}
+
+//Files of one batch share s3 client, so destinations must have the same credentials, timeout and S3Options
+func sameClient(a, b *models.ParsedDestination) bool {
+	if a.Timeout != b.Timeout || !reflect.DeepEqual(a.Credentials, b.Credentials) {
+		return false
+	}
+	return a.S3 == b.S3 || reflect.DeepEqual(a.S3, b.S3)
+}
+
+//Deletes all objects with key prefix of destination url, including nested "directories", and returns deleted keys.
+//Url of bucket root is refused, unless options.AllowBucketRoot is set. With options.DryRun keys are only listed.
+//Returns *BatchDeleteError, if some keys were not deleted
+func (s *S3Downloader) RemovePrefix(destination *models.ParsedDestination, options *models.RemoveOptions) ([]string, error) {
+	if options == nil {
+		options = &models.RemoveOptions{}
+	}
+	client, err := s.getClient(destination)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := newBrowseIterator(client, destination, true)
+	if err != nil {
+		return nil, err
+	}
+	if aws.StringValue(iterator.input.Prefix) == "" && !options.AllowBucketRoot {
+		return nil, fmt.Errorf("s3 url has no key prefix, set AllowBucketRoot to remove all objects of bucket: %s", destination.Url)
+	}
+
+	iterator.withPlaceholder = true
+
+	deleter := &batchDeleter{client: client, bucket: iterator.location.Bucket, dryRun: options.DryRun}
+	for iterator.Next() {
+		location, err := iterator.RemoteFile().ParsedDestination.GetS3Location()
+		if err != nil {
+			return deleter.removed, err
+		}
+		if err := deleter.add(&s3.ObjectIdentifier{Key: aws.String(location.Key)}); err != nil {
+			return deleter.removed, deleter.fail(err)
+		}
+	}
+	if err := iterator.Err(); err != nil {
+		return deleter.removed, err
+	}
+	err = deleter.close()
+	return deleter.removed, err
+}
+
+//Collects keys and deletes them by batches. Per-key errors are accumulated until close
+type batchDeleter struct {
+	client  *s3.S3
+	bucket  string
+	dryRun  bool
+	keys    []*s3.ObjectIdentifier
+	removed []string
+	errors  []KeyError
+}
+
+func (deleter *batchDeleter) add(object *s3.ObjectIdentifier) error {
+	deleter.keys = append(deleter.keys, object)
+	if len(deleter.keys) == deleteBatchSize {
+		return deleter.flush()
+	}
+	return nil
+}
+
+func (deleter *batchDeleter) flush() error {
+	if len(deleter.keys) == 0 {
+		return nil
+	}
+	keys := deleter.keys
+	deleter.keys = nil
+	if deleter.dryRun {
+		for _, key := range keys {
+			deleter.removed = append(deleter.removed, aws.StringValue(key.Key))
+		}
+		return nil
+	}
+	out, err := deleter.client.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(deleter.bucket),
+		Delete: &s3.Delete{Objects: keys, Quiet: aws.Bool(true)},
+	})
+	if err != nil {
+		return err
+	}
+	//quiet reply contains failed keys only
+	failed := make(map[string]bool)
+	for _, keyError := range out.Errors {
+		failed[aws.StringValue(keyError.Key)+"|"+aws.StringValue(keyError.VersionId)] = true
+		deleter.errors = append(deleter.errors, KeyError{
+			Bucket:    deleter.bucket,
+			Key:       aws.StringValue(keyError.Key),
+			VersionId: aws.StringValue(keyError.VersionId),
+			Code:      aws.StringValue(keyError.Code),
+			Message:   aws.StringValue(keyError.Message),
+		})
+	}
+	for _, key := range keys {
+		if !failed[aws.StringValue(key.Key)+"|"+aws.StringValue(key.VersionId)] {
+			deleter.removed = append(deleter.removed, aws.StringValue(key.Key))
+		}
+	}
+	return nil
+}
+
+//Deletes remaining keys and returns accumulated per-key errors
+func (deleter *batchDeleter) close() error {
+	if err := deleter.flush(); err != nil {
+		return deleter.fail(err)
+	}
+	if len(deleter.errors) > 0 {
+		return &BatchDeleteError{Errors: deleter.errors}
+	}
+	return nil
+}
+
+//Keeps per-key errors of previous requests together with request error
+func (deleter *batchDeleter) fail(err error) error {
+	if len(deleter.errors) > 0 {
+		return &BatchDeleteError{Errors: deleter.errors, Err: err}
+	}
+	return err
+}
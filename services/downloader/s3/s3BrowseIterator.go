@@ -0,0 +1,128 @@
+package s3
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Delimiter of s3 "directories"
+const s3Delimiter = "/"
+
+//Streaming iterator over s3 prefix. Next page of ListObjectsV2 is requested only after the current one is consumed,
+//so huge buckets can be listed without loading all keys into memory.
+//
+//	iterator, err := s3Downloader.NewBrowseIterator(destination, false)
+//	for iterator.Next() {
+//		file := iterator.RemoteFile()
+//	}
+//	err = iterator.Err()
+type BrowseIterator struct {
+	client      *s3.S3
+	destination *models.ParsedDestination
+	location    *models.S3Location
+	input       *s3.ListObjectsV2Input
+	//return folder placeholder object of prefix, which is skipped by Browse
+	withPlaceholder bool
+
+	page    []*models.RemoteFile
+	current *models.RemoteFile
+	done    bool
+	err     error
+}
+
+//Creates iterator over destination prefix. Non-recursive iterator returns direct children of prefix: objects and
+//IsDir entries for common prefixes. Recursive iterator returns all objects with the prefix
+func (s *S3Downloader) NewBrowseIterator(destination *models.ParsedDestination, recursive bool) (*BrowseIterator, error) {
+	client, err := s.getClient(destination)
+	if err != nil {
+		return nil, err
+	}
+	return newBrowseIterator(client, destination, recursive)
+}
+
+func newBrowseIterator(client *s3.S3, destination *models.ParsedDestination, recursive bool) (*BrowseIterator, error) {
+	location, err := destination.GetS3Location()
+	if err != nil {
+		return nil, err
+	}
+	//s3://bucket/dir is browsed as s3://bucket/dir/
+	prefix := location.Key
+	if !location.IsPrefix() {
+		prefix += s3Delimiter
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(location.Bucket),
+		Prefix: aws.String(prefix),
+	}
+	if !recursive {
+		input.Delimiter = aws.String(s3Delimiter)
+	}
+	return &BrowseIterator{client: client, destination: destination, location: location, input: input}, nil
+}
+
+//Advances iterator to the next file. Returns false, when there are no more files or an error occurred
+func (iterator *BrowseIterator) Next() bool {
+	for len(iterator.page) == 0 {
+		if iterator.done || iterator.err != nil {
+			iterator.current = nil
+			return false
+		}
+		iterator.err = iterator.fetch()
+	}
+	iterator.current = iterator.page[0]
+	iterator.page = iterator.page[1:]
+	return true
+}
+
+//Returns current file
+func (iterator *BrowseIterator) RemoteFile() *models.RemoteFile {
+	return iterator.current
+}
+
+//Returns error, which stopped iteration
+func (iterator *BrowseIterator) Err() error {
+	return iterator.err
+}
+
+//Requests next page of listing
+func (iterator *BrowseIterator) fetch() error {
+	out, err := iterator.client.ListObjectsV2(iterator.input)
+	if err != nil {
+		return err
+	}
+
+	prefix := aws.StringValue(iterator.input.Prefix)
+	page := make([]*models.RemoteFile, 0, len(out.CommonPrefixes)+len(out.Contents))
+	for _, commonPrefix := range out.CommonPrefixes {
+		file, err := newRemoteFile(iterator.destination, iterator.location, aws.StringValue(commonPrefix.Prefix), "")
+		if err != nil {
+			return err
+		}
+		file.IsDir = true
+		page = append(page, file)
+	}
+	for _, object := range out.Contents {
+		//folder placeholder object
+		if aws.StringValue(object.Key) == prefix && !iterator.withPlaceholder {
+			continue
+		}
+		file, err := newRemoteFile(iterator.destination, iterator.location, aws.StringValue(object.Key), "")
+		if err != nil {
+			return err
+		}
+		file.Size = aws.Int64Value(object.Size)
+		file.Lastmod = aws.TimeValue(object.LastModified)
+		file.ETag = aws.StringValue(object.ETag)
+		page = append(page, file)
+	}
+	iterator.page = page
+
+	if aws.BoolValue(out.IsTruncated) && aws.StringValue(out.NextContinuationToken) != "" {
+		iterator.input.ContinuationToken = out.NextContinuationToken
+	} else {
+		iterator.done = true
+	}
+	return nil
+}
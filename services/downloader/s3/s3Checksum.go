@@ -0,0 +1,147 @@
+package s3
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Flexible checksums headers. Aws sdk does not support flexible checksums yet, so they are set and read as plain headers
+const (
+	checksumModeHeader   = "X-Amz-Checksum-Mode"
+	checksumCRC32CHeader = "X-Amz-Checksum-Crc32c"
+	checksumSHA256Header = "X-Amz-Checksum-Sha256"
+)
+
+//Returned by Download, when downloaded data does not match checksum of s3 object
+type ChecksumMismatchError struct {
+	Key string
+	// ETag (md5), CRC32C or SHA256
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch of s3 object %s: expected %s, got %s", e.Algorithm, e.Key, e.Expected, e.Actual)
+}
+
+//Returns part size and concurrency of download. Concurrency is reduced to fit PartSize×Concurrency into
+//MaxInFlightBytes, part size is never reduced
+func downloadTuning(options *models.S3Options) (int64, int, error) {
+	partSize, concurrency := int64(s3manager.DefaultDownloadPartSize), s3manager.DefaultDownloadConcurrency
+	if options == nil {
+		return partSize, concurrency, nil
+	}
+	if options.PartSize < 0 || options.Concurrency < 0 || options.MaxInFlightBytes < 0 {
+		return 0, 0, errors.New("s3 part size, concurrency and max in-flight bytes must not be negative")
+	}
+	if options.PartSize > 0 {
+		partSize = options.PartSize
+	}
+	if options.Concurrency > 0 {
+		concurrency = options.Concurrency
+	}
+	if maxBytes := options.MaxInFlightBytes; maxBytes > 0 {
+		if maxBytes < partSize {
+			return 0, 0, fmt.Errorf("s3 max in-flight bytes %d is less than part size %d", maxBytes, partSize)
+		}
+		if int64(concurrency)*partSize > maxBytes {
+			concurrency = int(maxBytes / partSize)
+		}
+	}
+	return partSize, concurrency, nil
+}
+
+//Checks downloaded file against SHA256, CRC32C and md5 ETag of s3 object. Checksums of multipart objects are skipped,
+//as they are computed from part checksums
+func validateChecksums(localFile *os.File, key string, object *models.RemoteFile) error {
+	if _, err := localFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	md5Hash, sha256Hash, crc32cHash := md5.New(), sha256.New(), crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash, crc32cHash), localFile); err != nil {
+		return err
+	}
+
+	if expected := object.S3.ChecksumSHA256; expected != "" && !isCompositeChecksum(expected) {
+		if actual := base64.StdEncoding.EncodeToString(sha256Hash.Sum(nil)); actual != expected {
+			return &ChecksumMismatchError{Key: key, Algorithm: "SHA256", Expected: expected, Actual: actual}
+		}
+	}
+	if expected := object.S3.ChecksumCRC32C; expected != "" && !isCompositeChecksum(expected) {
+		sum := make([]byte, 4)
+		binary.BigEndian.PutUint32(sum, crc32cHash.Sum32())
+		if actual := base64.StdEncoding.EncodeToString(sum); actual != expected {
+			return &ChecksumMismatchError{Key: key, Algorithm: "CRC32C", Expected: expected, Actual: actual}
+		}
+	}
+	if isMD5ETag(object) {
+		expected := strings.Trim(object.ETag, `"`)
+		if actual := hex.EncodeToString(md5Hash.Sum(nil)); !strings.EqualFold(actual, expected) {
+			return &ChecksumMismatchError{Key: key, Algorithm: "ETag", Expected: expected, Actual: actual}
+		}
+	}
+	return nil
+}
+
+func isCompositeChecksum(checksum string) bool {
+	return strings.Contains(checksum, "-")
+}
+
+//ETag is md5 of data for single-part objects, which are not encrypted with KMS or customer key
+func isMD5ETag(object *models.RemoteFile) bool {
+	etag := strings.Trim(object.ETag, `"`)
+	if len(etag) != md5.Size*2 || object.S3.ServerSideEncryption == s3.ServerSideEncryptionAwsKms || object.S3.SSECustomerAlgorithm != "" {
+		return false
+	}
+	_, err := hex.DecodeString(etag)
+	return err == nil
+}
+
+//Returns checksum of s3 object from its metadata without reading data: md5 from ETag of single-part objects, SHA256
+//and CRC32C from flexible checksums, which were uploaded with object
+func (s *S3Downloader) Checksum(remoteFile *models.RemoteFile, algorithm models.ChecksumAlgorithm) (string, error) {
+	client, err := s.getClient(remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+	object, err := s.stat(client, remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+	return objectChecksum(object, algorithm)
+}
+
+func objectChecksum(object *models.RemoteFile, algorithm models.ChecksumAlgorithm) (string, error) {
+	var checksum string
+	switch algorithm {
+	case models.ChecksumMD5:
+		if isMD5ETag(object) {
+			return strings.ToLower(strings.Trim(object.ETag, `"`)), nil
+		}
+	case models.ChecksumSHA256:
+		checksum = object.S3.ChecksumSHA256
+	case models.ChecksumCRC32C:
+		checksum = object.S3.ChecksumCRC32C
+	}
+	//checksums of multipart objects are computed from part checksums, so they are not checksums of data
+	if checksum != "" && !isCompositeChecksum(checksum) {
+		if sum, err := base64.StdEncoding.DecodeString(checksum); err == nil {
+			return hex.EncodeToString(sum), nil
+		}
+	}
+	return "", fmt.Errorf("s3 object %s has no %s checksum", object.ParsedDestination.Url, algorithm)
+}
@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Providers of chain, if S3Credentials.Providers is not set
+var defaultCredentialsProviders = []models.S3CredentialsProvider{
+	models.S3ProviderStatic, models.S3ProviderEnv, models.S3ProviderShared, models.S3ProviderWebIdentity,
+}
+
+//Builds credentials from destination S3Credentials chain. STS requests use region and http client of s3Config
+func newCredentials(destination *models.ParsedDestination, s3Config *aws.Config) (*credentials.Credentials, error) {
+	options := destination.Credentials.S3Credentials
+	if options == nil {
+		return credentials.NewStaticCredentials(destination.GetUser(), destination.GetPassword(), ""), nil
+	}
+
+	names := options.Providers
+	if len(names) == 0 {
+		names = defaultCredentialsProviders
+	}
+	providers := make([]credentials.Provider, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case models.S3ProviderStatic:
+			providers = append(providers, &credentials.StaticProvider{Value: credentials.Value{
+				AccessKeyID:     destination.GetUser(),
+				SecretAccessKey: destination.GetPassword(),
+				SessionToken:    options.SessionToken,
+			}})
+		case models.S3ProviderEnv:
+			providers = append(providers, &credentials.EnvProvider{})
+		case models.S3ProviderShared:
+			providers = append(providers, sharedProviders(options)...)
+		case models.S3ProviderWebIdentity:
+			tokenFile := firstNonEmpty(options.WebIdentityTokenFile, os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+			//provider is skipped without token, like in aws sdk default chain
+			if tokenFile == "" {
+				continue
+			}
+			//AssumeRoleWithWebIdentity requests are not signed
+			stsClient, err := newStsClient(options, s3Config, credentials.AnonymousCredentials)
+			if err != nil {
+				return nil, err
+			}
+			roleArn := firstNonEmpty(options.WebIdentityRoleArn, os.Getenv("AWS_ROLE_ARN"))
+			providers = append(providers, stscreds.NewWebIdentityRoleProvider(stsClient, roleArn, options.RoleSessionName, tokenFile))
+		default:
+			return nil, fmt.Errorf("unknown s3 credentials provider: %s", name)
+		}
+	}
+	chain := credentials.NewCredentials(&credentials.ChainProvider{Providers: providers, VerboseErrors: true})
+	if options.RoleArn == "" {
+		return chain, nil
+	}
+
+	stsClient, err := newStsClient(options, s3Config, chain)
+	if err != nil {
+		return nil, err
+	}
+	return stscreds.NewCredentialsWithClient(stsClient, options.RoleArn, func(provider *stscreds.AssumeRoleProvider) {
+		provider.RoleSessionName = options.RoleSessionName
+		if options.ExternalId != "" {
+			provider.ExternalID = aws.String(options.ExternalId)
+		}
+	}), nil
+}
+
+//Returns providers of profile from shared credentials file and shared config file
+func sharedProviders(options *models.S3Credentials) []credentials.Provider {
+	profile := firstNonEmpty(options.Profile, os.Getenv("AWS_PROFILE"), "default")
+	configFile := firstNonEmpty(options.SharedConfigFile, os.Getenv("AWS_CONFIG_FILE"), defaults.SharedConfigFilename())
+	//config file sections of named profiles are "[profile name]"
+	configProfile := profile
+	if profile != "default" {
+		configProfile = "profile " + profile
+	}
+	return []credentials.Provider{
+		&credentials.SharedCredentialsProvider{Filename: options.SharedCredentialsFile, Profile: profile},
+		&credentials.SharedCredentialsProvider{Filename: configFile, Profile: configProfile},
+	}
+}
+
+func newStsClient(options *models.S3Credentials, s3Config *aws.Config, creds *credentials.Credentials) (*sts.STS, error) {
+	stsConfig := &aws.Config{
+		Credentials: creds,
+		Region:      s3Config.Region,
+		HTTPClient:  s3Config.HTTPClient,
+	}
+	if options.StsEndpoint != "" {
+		stsConfig.Endpoint = aws.String(options.StsEndpoint)
+	}
+	sess, err := session.NewSession(stsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return sts.New(sess), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -1,11 +1,17 @@
 package s3
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -15,32 +21,17 @@ import (
 
 type S3Downloader struct{}
 
-// переписать
+//Returns object by key from s3://bucket/key or http(s)://endpoint/bucket/key url
 func (s *S3Downloader) Stat(destination *models.ParsedDestination) (*models.RemoteFile, error) {
 	client, err := s.getClient(destination)
 	if err != nil {
 		return nil, err
 	}
 
-	arr := strings.SplitAfterN(destination.GetPath(), "/", 2)
-	path := arr[1]
-
-	files, err := s.browse(client, destination)
-	if err != nil {
-		return nil, err
-	}
-
-	var remoteFile *models.RemoteFile
-	for _, f := range files {
-		if f.Name == path {
-			remoteFile = f
-			break
-		}
-	}
-
-	return remoteFile, nil
+	return s.stat(client, destination)
 }
 
+//Returns objects and "directories" (common prefixes) directly under key prefix of destination url
 func (s *S3Downloader) Browse(destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
 	client, err := s.getClient(destination)
 	if err != nil {
@@ -60,15 +51,39 @@ func (s *S3Downloader) Download(file *models.RemoteFile) (*models.RemoteFileCont
 }
 
 func (s *S3Downloader) Remove(remoteFile *models.RemoteFile) error {
-	return nil
+	client, err := s.getClient(remoteFile.ParsedDestination)
+	if err != nil {
+		return err
+	}
+
+	return s.remove(client, remoteFile)
 }
 
 func (s *S3Downloader) getClient(destination *models.ParsedDestination) (*s3.S3, error) {
-	s3Config := &aws.Config{
-		Credentials:      credentials.NewStaticCredentials(destination.GetUser(), destination.GetPassword(), ""),
-		Endpoint:         aws.String(destination.GetHost()),
-		Region:           aws.String("ru-central1"), // временный костыль
-		S3ForcePathStyle: aws.Bool(true),
+	s3Config, err := s.getConfig(destination)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateEncryption(destination.Credentials.S3Encryption); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := destination.GetTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		s3Config.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		}
+	}
+
+	s3Config.Credentials, err = newCredentials(destination, s3Config)
+	if err != nil {
+		return nil, err
 	}
 
 	sess, err := session.NewSession(s3Config)
@@ -77,26 +92,172 @@ func (s *S3Downloader) getClient(destination *models.ParsedDestination) (*s3.S3,
 	}
 
 	svc := s3.New(sess)
+	if options := destination.S3; options != nil && options.SignatureVersion == models.S3SignatureV2 {
+		svc.Handlers.Sign.Clear()
+		svc.Handlers.Sign.PushBackNamed(signV2Handler)
+	}
 
 	return svc, nil
 }
 
-func (s *S3Downloader) download(client *s3.S3, remoteFile *models.RemoteFile) (*models.RemoteFileContent, error) {
-	localFile, err := ioutil.TempFile("", remoteFile.Name+".*")
+//Builds aws config from destination url and S3Options
+func (s *S3Downloader) getConfig(destination *models.ParsedDestination) (*aws.Config, error) {
+	options := destination.S3
+	if options == nil {
+		options = &models.S3Options{}
+	}
+
+	region := options.Region
+	if region == "" {
+		region = models.DefaultS3Region
+	}
+
+	style := options.GetAddressingStyle()
+	switch style {
+	case models.S3PathStyle:
+		if options.Accelerate {
+			return nil, errors.New("s3 accelerate requires virtual-hosted addressing style")
+		}
+	case models.S3VirtualHostedStyle:
+	default:
+		return nil, fmt.Errorf("unknown s3 addressing style: %s", style)
+	}
+
+	switch options.SignatureVersion {
+	case "", models.S3SignatureV4, models.S3SignatureV2:
+	default:
+		return nil, fmt.Errorf("unknown s3 signature version: %s", options.SignatureVersion)
+	}
+
+	s3Config := &aws.Config{
+		Region:           aws.String(region),
+		S3ForcePathStyle: aws.Bool(style == models.S3PathStyle),
+		UseDualStack:     aws.Bool(options.DualStack),
+		S3UseAccelerate:  aws.Bool(options.Accelerate),
+	}
+
+	location, err := destination.GetS3Location()
 	if err != nil {
 		return nil, err
 	}
 
-	defer localFile.Close()
+	//dual-stack, accelerate and s3:// url endpoints are resolved by aws sdk from region
+	switch {
+	case options.Endpoint != "":
+		s3Config.Endpoint = aws.String(options.Endpoint)
+	case !options.DualStack && !options.Accelerate && location.Endpoint != "":
+		s3Config.Endpoint = aws.String(location.Endpoint)
+	}
+
+	return s3Config, nil
+}
+
+func (s *S3Downloader) stat(client *s3.S3, destination *models.ParsedDestination) (*models.RemoteFile, error) {
+	location, err := destination.GetS3Location()
+	if err != nil {
+		return nil, err
+	}
+	if location.IsPrefix() {
+		return nil, fmt.Errorf("s3 url has no object key: %s", destination.Url)
+	}
+
+	in := &s3.HeadObjectInput{
+		Bucket:    aws.String(location.Bucket),
+		Key:       aws.String(location.Key),
+		VersionId: versionIdParam(location),
+	}
+	in.SSECustomerAlgorithm, in.SSECustomerKey = customerKeyParams(destination)
+	req, out := client.HeadObjectRequest(in)
+	req.HTTPRequest.Header.Set(checksumModeHeader, "ENABLED")
+	if err := req.Send(); err != nil {
+		if isNotFound(err) {
+			return nil, models.NewNotFoundError(destination.Url)
+		}
+		return nil, err
+	}
+
+	file, err := newRemoteFile(destination, location, location.Key, location.VersionId)
+	if err != nil {
+		return nil, err
+	}
+	file.Size = aws.Int64Value(out.ContentLength)
+	file.Lastmod = aws.TimeValue(out.LastModified)
+	file.ETag = aws.StringValue(out.ETag)
+	file.ContentType = aws.StringValue(out.ContentType)
+	file.S3 = &models.S3ObjectInfo{
+		StorageClass:         aws.StringValue(out.StorageClass),
+		VersionId:            aws.StringValue(out.VersionId),
+		Metadata:             aws.StringValueMap(out.Metadata),
+		ServerSideEncryption: aws.StringValue(out.ServerSideEncryption),
+		SSEKMSKeyId:          aws.StringValue(out.SSEKMSKeyId),
+		SSECustomerAlgorithm: aws.StringValue(out.SSECustomerAlgorithm),
+		ChecksumCRC32C:       req.HTTPResponse.Header.Get(checksumCRC32CHeader),
+		ChecksumSHA256:       req.HTTPResponse.Header.Get(checksumSHA256Header),
+	}
+	//s3 does not return storage class header for STANDARD objects
+	if file.S3.StorageClass == "" {
+		file.S3.StorageClass = s3.StorageClassStandard
+	}
+	return file, nil
+}
+
+//Returns true for 404 responses. HEAD responses have no body, so error code is not always NoSuchKey
+func isNotFound(err error) bool {
+	if requestFailure, ok := err.(awserr.RequestFailure); ok {
+		return requestFailure.StatusCode() == http.StatusNotFound
+	}
+	return false
+}
+
+func (s *S3Downloader) download(client *s3.S3, remoteFile *models.RemoteFile) (*models.RemoteFileContent, error) {
+	location, err := remoteFile.ParsedDestination.GetS3Location()
+	if err != nil {
+		return nil, err
+	}
+	if location.IsPrefix() {
+		return nil, fmt.Errorf("s3 url has no object key: %s", remoteFile.ParsedDestination.Url)
+	}
+	partSize, concurrency, err := downloadTuning(remoteFile.ParsedDestination.S3)
+	if err != nil {
+		return nil, err
+	}
 
 	in := s3.GetObjectInput{
-		Bucket: aws.String(strings.TrimPrefix(remoteFile.ParsedDestination.GetPath(), "/")),
-		Key:    aws.String(remoteFile.Name),
+		Bucket:    aws.String(location.Bucket),
+		Key:       aws.String(location.Key),
+		VersionId: versionIdParam(location),
 	}
+	in.SSECustomerAlgorithm, in.SSECustomerKey = customerKeyParams(remoteFile.ParsedDestination)
 
-	dm := s3manager.NewDownloaderWithClient(client)
+	//checksums are returned by HEAD only, as parts are downloaded with ranged GETs.
+	//If-Match makes sure, that all parts belong to the object with these checksums
+	var object *models.RemoteFile
+	validate := remoteFile.ParsedDestination.S3 == nil || !remoteFile.ParsedDestination.S3.SkipChecksumValidation
+	if validate {
+		object, err = s.stat(client, remoteFile.ParsedDestination)
+		if err != nil {
+			return nil, err
+		}
+		in.IfMatch = aws.String(object.ETag)
+	}
+
+	localFile, err := ioutil.TempFile("", remoteFile.Name+".*")
+	if err != nil {
+		return nil, err
+	}
+
+	defer localFile.Close()
+
+	dm := s3manager.NewDownloaderWithClient(client, func(d *s3manager.Downloader) {
+		d.PartSize = partSize
+		d.Concurrency = concurrency
+	})
 	_, err = dm.Download(localFile, &in)
+	if err == nil && validate {
+		err = validateChecksums(localFile, location.Key, object)
+	}
 	if err != nil {
+		_ = os.Remove(localFile.Name())
 		return nil, err
 	}
 
@@ -110,26 +271,63 @@ func (s *S3Downloader) download(client *s3.S3, remoteFile *models.RemoteFile) (*
 }
 
 func (s *S3Downloader) browse(client *s3.S3, destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
-	in := s3.ListObjectsInput{
-		Bucket: aws.String(strings.TrimPrefix(destination.GetPath(), "/")),
-	}
-
-	out, err := client.ListObjects(&in)
+	iterator, err := newBrowseIterator(client, destination, false)
 	if err != nil {
 		return nil, err
 	}
 
-	files := make([]*models.RemoteFile, len(out.Contents))
-	for i, o := range out.Contents {
-		file := &models.RemoteFile{
-			Name:              *o.Key,
-			Path:              *o.Key,
-			ParsedDestination: destination,
-			Size:              *o.Size,
-			Lastmod:           *o.LastModified,
-		}
-		files[i] = file
+	files := make([]*models.RemoteFile, 0)
+	for iterator.Next() {
+		files = append(files, iterator.RemoteFile())
+	}
+	if err := iterator.Err(); err != nil {
+		return nil, err
 	}
 
 	return files, nil
 }
+
+func (s *S3Downloader) remove(client *s3.S3, remoteFile *models.RemoteFile) error {
+	location, err := remoteFile.ParsedDestination.GetS3Location()
+	if err != nil {
+		return err
+	}
+	if location.IsPrefix() {
+		return fmt.Errorf("s3 url has no object key: %s", remoteFile.ParsedDestination.Url)
+	}
+
+	//deleting specific version removes it permanently, deleting the latest one adds delete marker to versioned bucket
+	_, err = client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket:    aws.String(location.Bucket),
+		Key:       aws.String(location.Key),
+		VersionId: versionIdParam(location),
+	})
+	return err
+}
+
+//Creates remote file for object key or common prefix. Each file gets own ParsedDestination with url of the key
+//and version, so it can be downloaded and removed
+func newRemoteFile(destination *models.ParsedDestination, location *models.S3Location, key, versionId string) (*models.RemoteFile, error) {
+	parsedUrl, err := url.Parse(location.KeyVersionUrl(key, versionId))
+	if err != nil {
+		return nil, err
+	}
+	child := *destination
+	child.ParsedUrl = parsedUrl
+	child.Url = parsedUrl.String()
+
+	dir, name := path.Split(strings.TrimSuffix(parsedUrl.Path, s3Delimiter))
+	return &models.RemoteFile{
+		Name:              name,
+		Path:              dir,
+		ParsedDestination: &child,
+	}, nil
+}
+
+//Returns VersionId request parameter. Nil for the latest version
+func versionIdParam(location *models.S3Location) *string {
+	if location.VersionId == "" {
+		return nil
+	}
+	return aws.String(location.VersionId)
+}
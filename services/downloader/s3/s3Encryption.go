@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//SSE-C keys are AES-256 keys
+const customerKeyLength = 32
+
+func validateEncryption(encryption *models.S3Encryption) error {
+	if encryption == nil {
+		return nil
+	}
+	switch encryption.ServerSideEncryption {
+	case "", models.S3EncryptionAES256, models.S3EncryptionKMS:
+	default:
+		return fmt.Errorf("unknown s3 server-side encryption: %s", encryption.ServerSideEncryption)
+	}
+	if encryption.KMSKeyId != "" && encryption.ServerSideEncryption != models.S3EncryptionKMS {
+		return errors.New("s3 kms key id requires aws:kms server-side encryption")
+	}
+	if len(encryption.CustomerKey) > 0 {
+		if len(encryption.CustomerKey) != customerKeyLength {
+			return fmt.Errorf("s3 customer key must be %d bytes long", customerKeyLength)
+		}
+		if encryption.ServerSideEncryption != "" {
+			return errors.New("s3 customer key can not be used with server-side encryption")
+		}
+	}
+	return nil
+}
+
+//Returns SSE-C algorithm and key request parameters. Nil, if customer key is not set
+func customerKeyParams(destination *models.ParsedDestination) (*string, *string) {
+	encryption := destination.Credentials.S3Encryption
+	if encryption == nil || len(encryption.CustomerKey) == 0 {
+		return nil, nil
+	}
+	//aws sdk computes key md5 and encodes key to base64
+	return aws.String(s3.ServerSideEncryptionAes256), aws.String(string(encryption.CustomerKey))
+}
+
+//Sets encryption parameters of object written by CopyObject. Source object is read with the same customer key
+func applyCopyEncryption(destination *models.ParsedDestination, input *s3.CopyObjectInput) {
+	input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey = customerKeyParams(destination)
+	input.SSECustomerAlgorithm, input.SSECustomerKey = customerKeyParams(destination)
+	input.ServerSideEncryption, input.SSEKMSKeyId = serverSideEncryptionParams(destination)
+}
+
+//Returns SSE-S3 or SSE-KMS encryption and KMS key id request parameters of written objects. Nil, if not set
+func serverSideEncryptionParams(destination *models.ParsedDestination) (*string, *string) {
+	encryption := destination.Credentials.S3Encryption
+	if encryption == nil || encryption.ServerSideEncryption == "" {
+		return nil, nil
+	}
+	return aws.String(string(encryption.ServerSideEncryption)), optionalString(encryption.KMSKeyId)
+}
@@ -0,0 +1,106 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Returns url, which downloads remote file without credentials until expiration. Url keeps versionId of the file.
+//Presigned urls can not be used with SSE-C customer key, as the key must be sent in request headers
+func (s *S3Downloader) PresignGet(remoteFile *models.RemoteFile, options *models.PresignOptions) (string, error) {
+	client, err := s.getClient(remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+	return s.presignGet(client, remoteFile, options)
+}
+
+//Returns url, which uploads remote file with PUT request without credentials until expiration. Server-side encryption
+//of Credentials.S3Encryption is signed, so PUT request must send the same x-amz-server-side-encryption and
+//x-amz-server-side-encryption-aws-kms-key-id headers
+func (s *S3Downloader) PresignPut(remoteFile *models.RemoteFile, options *models.PresignOptions) (string, error) {
+	client, err := s.getClient(remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+	return s.presignPut(client, remoteFile, options)
+}
+
+func (s *S3Downloader) presignGet(client *s3.S3, remoteFile *models.RemoteFile, options *models.PresignOptions) (string, error) {
+	location, err := presignLocation(remoteFile)
+	if err != nil {
+		return "", err
+	}
+	if options == nil {
+		options = &models.PresignOptions{}
+	}
+	req, _ := client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     aws.String(location.Bucket),
+		Key:                        aws.String(location.Key),
+		VersionId:                  versionIdParam(location),
+		ResponseContentType:        optionalString(options.ResponseContentType),
+		ResponseContentDisposition: optionalString(options.ResponseContentDisposition),
+		ResponseCacheControl:       optionalString(options.ResponseCacheControl),
+		ResponseContentEncoding:    optionalString(options.ResponseContentEncoding),
+		ResponseContentLanguage:    optionalString(options.ResponseContentLanguage),
+	})
+	return presign(req, options)
+}
+
+func (s *S3Downloader) presignPut(client *s3.S3, remoteFile *models.RemoteFile, options *models.PresignOptions) (string, error) {
+	location, err := presignLocation(remoteFile)
+	if err != nil {
+		return "", err
+	}
+	if location.VersionId != "" {
+		return "", fmt.Errorf("s3 object version can not be overwritten: %s", remoteFile.ParsedDestination.Url)
+	}
+	if options == nil {
+		options = &models.PresignOptions{}
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(location.Bucket),
+		Key:         aws.String(location.Key),
+		ContentType: optionalString(options.ContentType),
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = serverSideEncryptionParams(remoteFile.ParsedDestination)
+	req, _ := client.PutObjectRequest(input)
+	return presign(req, options)
+}
+
+func presignLocation(remoteFile *models.RemoteFile) (*models.S3Location, error) {
+	location, err := remoteFile.ParsedDestination.GetS3Location()
+	if err != nil {
+		return nil, err
+	}
+	if location.IsPrefix() {
+		return nil, fmt.Errorf("s3 url has no object key: %s", remoteFile.ParsedDestination.Url)
+	}
+	if encryption := remoteFile.ParsedDestination.Credentials.S3Encryption; encryption != nil && len(encryption.CustomerKey) > 0 {
+		return nil, errors.New("s3 presigned urls can not be used with customer key")
+	}
+	return location, nil
+}
+
+func presign(req *request.Request, options *models.PresignOptions) (string, error) {
+	expires := options.Expires
+	if expires == 0 {
+		expires = models.DefaultPresignExpires
+	}
+	if expires < 0 || expires > models.MaxPresignExpires {
+		return "", fmt.Errorf("s3 presigned url expiry must be positive and not exceed %v", models.MaxPresignExpires)
+	}
+	return req.Presign(expires)
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
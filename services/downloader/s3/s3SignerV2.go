@@ -0,0 +1,122 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+//Sign handler replacing SigV4 for storages supporting legacy S3 signature version 2 only
+var signV2Handler = request.NamedHandler{Name: "s3.SignV2Handler", Fn: signV2}
+
+//Query parameters, which are part of the signed resource
+var signV2SubResources = map[string]bool{
+	"acl": true, "cors": true, "delete": true, "lifecycle": true, "location": true, "logging": true,
+	"notification": true, "partNumber": true, "policy": true, "requestPayment": true, "restore": true,
+	"tagging": true, "torrent": true, "uploadId": true, "uploads": true, "versionId": true,
+	"versioning": true, "versions": true, "website": true,
+	"response-cache-control": true, "response-content-disposition": true, "response-content-encoding": true,
+	"response-content-language": true, "response-content-type": true, "response-expires": true,
+}
+
+func signV2(r *request.Request) {
+	if r.Config.Credentials == credentials.AnonymousCredentials {
+		return
+	}
+	creds, err := r.Config.Credentials.Get()
+	if err != nil {
+		r.Error = err
+		return
+	}
+
+	req := r.HTTPRequest
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	req.Header.Del("X-Amz-Date")
+
+	bucket := ""
+	if values, err := awsutil.ValuesAtPath(r.Params, "Bucket"); err == nil && len(values) > 0 {
+		if name, ok := values[0].(*string); ok && name != nil {
+			bucket = *name
+		}
+	}
+
+	//presigned requests use query string authentication with expiration time instead of date
+	if r.ExpireTime > 0 {
+		expires := strconv.FormatInt(time.Now().Add(r.ExpireTime).Unix(), 10)
+		query := req.URL.Query()
+		query.Set("AWSAccessKeyId", creds.AccessKeyID)
+		query.Set("Expires", expires)
+		query.Set("Signature", signatureV2(creds.SecretAccessKey, stringToSignV2(req, bucket, expires)))
+		if creds.SessionToken != "" {
+			req.Header.Del("X-Amz-Security-Token")
+			query.Set("x-amz-security-token", creds.SessionToken)
+		}
+		req.URL.RawQuery = query.Encode()
+		return
+	}
+
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("Date", date)
+	req.Header.Set("Authorization", "AWS "+creds.AccessKeyID+":"+signatureV2(creds.SecretAccessKey, stringToSignV2(req, bucket, date)))
+}
+
+func signatureV2(secretAccessKey, stringToSign string) string {
+	mac := hmac.New(sha1.New, []byte(secretAccessKey))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//Builds string to sign: method, content md5, content type, date or expiration time, x-amz-* headers and resource
+func stringToSignV2(req *http.Request, bucket, date string) string {
+	var builder strings.Builder
+	builder.WriteString(req.Method + "\n")
+	builder.WriteString(req.Header.Get("Content-MD5") + "\n")
+	builder.WriteString(req.Header.Get("Content-Type") + "\n")
+	builder.WriteString(date + "\n")
+
+	amzHeaders := make([]string, 0)
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			amzHeaders = append(amzHeaders, name+":"+strings.Join(values, ","))
+		}
+	}
+	sort.Strings(amzHeaders)
+	for _, header := range amzHeaders {
+		builder.WriteString(header + "\n")
+	}
+
+	//virtual-hosted style requests have bucket in host, but it is still a part of signed resource
+	if bucket != "" && strings.HasPrefix(req.URL.Host, bucket+".") {
+		builder.WriteString("/" + bucket)
+	}
+	builder.WriteString(req.URL.EscapedPath())
+
+	subResources := make([]string, 0)
+	for name, values := range req.URL.Query() {
+		if !signV2SubResources[name] {
+			continue
+		}
+		if len(values) > 0 && values[0] != "" {
+			subResources = append(subResources, name+"="+values[0])
+		} else {
+			subResources = append(subResources, name)
+		}
+	}
+	sort.Strings(subResources)
+	if len(subResources) > 0 {
+		builder.WriteString("?" + strings.Join(subResources, "&"))
+	}
+	return builder.String()
+}
@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+//Returns versions and delete markers of the destination key, newest first. For prefix urls returns versions
+//of all keys with the prefix. Each version gets url with versionId, so it can be downloaded, removed or restored
+func (s *S3Downloader) ListVersions(destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
+	client, err := s.getClient(destination)
+	if err != nil {
+		return nil, err
+	}
+	return s.listVersions(client, destination)
+}
+
+//Makes a copy of the remote file version the latest version of its key. Returns the new version
+func (s *S3Downloader) RestoreVersion(remoteFile *models.RemoteFile) (*models.RemoteFile, error) {
+	client, err := s.getClient(remoteFile.ParsedDestination)
+	if err != nil {
+		return nil, err
+	}
+	return s.restoreVersion(client, remoteFile)
+}
+
+func (s *S3Downloader) listVersions(client *s3.S3, destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
+	location, err := destination.GetS3Location()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*models.RemoteFile, 0)
+	var pageErr error
+	add := func(key, versionId string, isLatest, isDeleteMarker bool, lastmod *time.Time) *models.RemoteFile {
+		//prefix listing of exact key also returns longer keys
+		if !location.IsPrefix() && key != location.Key {
+			return nil
+		}
+		file, err := newRemoteFile(destination, location, key, versionId)
+		if err != nil {
+			pageErr = err
+			return nil
+		}
+		file.Lastmod = aws.TimeValue(lastmod)
+		file.S3 = &models.S3ObjectInfo{VersionId: versionId, IsLatest: isLatest, IsDeleteMarker: isDeleteMarker}
+		files = append(files, file)
+		return file
+	}
+
+	err = client.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket: aws.String(location.Bucket),
+		Prefix: aws.String(location.Key),
+	}, func(out *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, version := range out.Versions {
+			file := add(aws.StringValue(version.Key), aws.StringValue(version.VersionId), aws.BoolValue(version.IsLatest), false, version.LastModified)
+			if file != nil {
+				file.Size = aws.Int64Value(version.Size)
+				file.ETag = aws.StringValue(version.ETag)
+				file.S3.StorageClass = aws.StringValue(version.StorageClass)
+			}
+		}
+		for _, marker := range out.DeleteMarkers {
+			add(aws.StringValue(marker.Key), aws.StringValue(marker.VersionId), aws.BoolValue(marker.IsLatest), true, marker.LastModified)
+		}
+		return pageErr == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pageErr != nil {
+		return nil, pageErr
+	}
+
+	//versions and delete markers are returned in separate lists
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Path+files[i].Name != files[j].Path+files[j].Name {
+			return files[i].Path+files[i].Name < files[j].Path+files[j].Name
+		}
+		return files[i].Lastmod.After(files[j].Lastmod)
+	})
+	return files, nil
+}
+
+func (s *S3Downloader) restoreVersion(client *s3.S3, remoteFile *models.RemoteFile) (*models.RemoteFile, error) {
+	location, err := remoteFile.ParsedDestination.GetS3Location()
+	if err != nil {
+		return nil, err
+	}
+	if location.IsPrefix() || location.VersionId == "" {
+		return nil, fmt.Errorf("s3 url has no object key or versionId: %s", remoteFile.ParsedDestination.Url)
+	}
+
+	copySource := (&url.URL{Path: location.Bucket + "/" + location.Key}).EscapedPath() + "?versionId=" + url.QueryEscape(location.VersionId)
+	in := &s3.CopyObjectInput{
+		Bucket:     aws.String(location.Bucket),
+		Key:        aws.String(location.Key),
+		CopySource: aws.String(copySource),
+	}
+	applyCopyEncryption(remoteFile.ParsedDestination, in)
+	out, err := client.CopyObject(in)
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := newRemoteFile(remoteFile.ParsedDestination, location, location.Key, aws.StringValue(out.VersionId))
+	if err != nil {
+		return nil, err
+	}
+	return s.stat(client, restored.ParsedDestination)
+}
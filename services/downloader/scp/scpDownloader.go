@@ -0,0 +1,215 @@
+// scp file downloader implementation
+package scp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"github.com/goodsru/go-universal-network-adapter/services/downloader/sshClient"
+	"golang.org/x/crypto/ssh"
+)
+
+// Permissions of uploaded files, if RemoteFile.Metadata has no mode
+const defaultUploadMode os.FileMode = 0644
+
+// scp file downloader implementation for servers without sftp subsystem. Files are stat and listed with ls,
+// removed with rm or rmdir and transferred with scp over ssh exec
+type ScpDownloader struct {
+	// Called with url of folder and lines of ls output, which were not parsed by Browse. Parsed files are returned
+	// without error anyway
+	OnListingWarnings func(url string, warnings []ListingWarning)
+}
+
+func (scpDownloader *ScpDownloader) Stat(destination *models.ParsedDestination) (*models.RemoteFile, error) {
+	client, err := sshClient.Dial(destination)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return scpDownloader.stat(client, destination)
+}
+
+func (scpDownloader *ScpDownloader) Browse(destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
+	client, err := sshClient.Dial(destination)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return scpDownloader.browse(client, destination)
+}
+
+func (scpDownloader *ScpDownloader) Download(remoteFile *models.RemoteFile) (*models.RemoteFileContent, error) {
+	client, err := sshClient.Dial(remoteFile.ParsedDestination)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return scpDownloader.download(client, remoteFile)
+}
+
+// Uploads file with scp. Mode of RemoteFile.Metadata is used as permissions of new file, 0644 if it is not set.
+// Content of unknown size (-1) is read to memory first, because scp sends size before content
+func (scpDownloader *ScpDownloader) Upload(remoteFile *models.RemoteFile, content io.Reader, size int64) error {
+	client, err := sshClient.Dial(remoteFile.ParsedDestination)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return scpDownloader.upload(client, remoteFile, content, size)
+}
+
+// Removes file with rm, or empty directory with rmdir
+func (scpDownloader *ScpDownloader) Remove(remoteFile *models.RemoteFile) error {
+	client, err := sshClient.Dial(remoteFile.ParsedDestination)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return scpDownloader.remove(client, remoteFile)
+}
+
+func (scpDownloader *ScpDownloader) stat(client *ssh.Client, destination *models.ParsedDestination) (*models.RemoteFile, error) {
+	return lsStat(client, destination, destination.GetPath())
+}
+
+// Stats path with ls -ld. Symbolic links are not followed
+func lsStat(client *ssh.Client, destination *models.ParsedDestination, filePath string) (*models.RemoteFile, error) {
+	filePath = strings.TrimSuffix(filePath, "/")
+	if filePath == "" {
+		filePath = "/"
+	}
+	output, err := run(client, destination, "ls -ld --full-time -- "+sshClient.Quote(filePath))
+	if err != nil {
+		return nil, err
+	}
+	line := strings.TrimRight(string(output), "\n")
+	if line == "" || strings.Contains(line, "\n") {
+		return nil, fmt.Errorf("invalid ls output: %q", output)
+	}
+	remoteFile, err := parseLsLine(line)
+	if err != nil {
+		return nil, err
+	}
+	//ls prints path as it was given
+	dir, name := path.Split(filePath)
+	remoteFile.Name = name
+	remoteFile.Path = dir
+	remoteFile.ParsedDestination = destination
+	return remoteFile, nil
+}
+
+func (scpDownloader *ScpDownloader) browse(client *ssh.Client, destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
+	folderPath := destination.GetPath()
+	output, err := run(client, destination, "ls -lA --full-time -- "+sshClient.Quote(folderPath))
+	if err != nil {
+		return nil, err
+	}
+
+	files, warnings := parseLsListing(string(output), folderPath, destination)
+	if len(warnings) > 0 && scpDownloader.OnListingWarnings != nil {
+		scpDownloader.OnListingWarnings(destination.Url, warnings)
+	}
+	return files, nil
+}
+
+// rm does not remove directories, and rm -r would remove their content too
+func (scpDownloader *ScpDownloader) remove(client *ssh.Client, remoteFile *models.RemoteFile) error {
+	filePath := path.Join(remoteFile.Path, remoteFile.Name)
+	stat, err := lsStat(client, remoteFile.ParsedDestination, filePath)
+	if err != nil {
+		return err
+	}
+	command := "rm -- "
+	if stat.IsDir {
+		command = "rmdir -- "
+	}
+	_, err = run(client, remoteFile.ParsedDestination, command+sshClient.Quote(filePath))
+	return err
+}
+
+func (scpDownloader *ScpDownloader) download(client *ssh.Client, remoteFile *models.RemoteFile) (*models.RemoteFileContent, error) {
+	session, err := startScp(client, "scp -f "+sshClient.Quote(path.Join(remoteFile.Path, remoteFile.Name)))
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	localFile, err := ioutil.TempFile("", remoteFile.Name+".*")
+	if err != nil {
+		return nil, err
+	}
+
+	err = session.receive(localFile)
+	localFile.Close()
+	if err != nil {
+		os.Remove(localFile.Name())
+		return nil, scpError(err, remoteFile.ParsedDestination)
+	}
+
+	return &models.RemoteFileContent{
+		Name: remoteFile.Name,
+		Path: localFile.Name(),
+		Blob: &models.Blob{
+			FilePath: localFile.Name(),
+		},
+	}, nil
+}
+
+func (scpDownloader *ScpDownloader) upload(client *ssh.Client, remoteFile *models.RemoteFile, content io.Reader, size int64) error {
+	if strings.ContainsAny(remoteFile.Name, "\n/") || remoteFile.Name == "" {
+		return fmt.Errorf("invalid scp file name: %q", remoteFile.Name)
+	}
+	if size < 0 {
+		data, err := ioutil.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		content, size = bytes.NewReader(data), int64(len(data))
+	}
+	mode := defaultUploadMode
+	if remoteFile.Metadata != nil && remoteFile.Metadata.Mode != nil {
+		mode = remoteFile.Metadata.Mode.Perm()
+	}
+
+	session, err := startScp(client, "scp -t "+sshClient.Quote(path.Join(remoteFile.Path, remoteFile.Name)))
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return scpError(session.send(remoteFile.Name, mode, content, size), remoteFile.ParsedDestination)
+}
+
+// Runs command and returns its output. Missing files are reported as models.ErrNotFound
+func run(client *ssh.Client, destination *models.ParsedDestination, command string) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	output, err := session.Output(command)
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if strings.Contains(message, "No such file") {
+			return nil, models.NewNotFoundError(destination.Url)
+		}
+		return nil, fmt.Errorf("%s failed: %v: %s", strings.Fields(command)[0], err, message)
+	}
+	return output, nil
+}
+
+func scpError(err error, destination *models.ParsedDestination) error {
+	if err != nil && strings.Contains(err.Error(), "No such file") {
+		return models.NewNotFoundError(destination.Url)
+	}
+	return err
+}
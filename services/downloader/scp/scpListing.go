@@ -0,0 +1,94 @@
+package scp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+)
+
+// Time of ls --full-time, i.e. "2020-03-17 15:04:05.123456789 +0300". Fractional seconds are optional
+const lsFullTimeLayout = "2006-01-02 15:04:05 -0700"
+
+// Columns of ls -l --full-time before file name: mode, links, owner, group, size, date, time and zone
+const lsColumns = 8
+
+// Line of ls output, which was not parsed
+type ListingWarning struct {
+	Line string
+	Err  error
+}
+
+// Parses ls -lA --full-time output into files of folder. Lines, which are not parsed, are skipped and returned
+// as warnings
+func parseLsListing(output string, folderPath string, destination *models.ParsedDestination) ([]*models.RemoteFile, []ListingWarning) {
+	var files []*models.RemoteFile
+	var warnings []ListingWarning
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" || strings.HasPrefix(line, "total ") {
+			continue
+		}
+		remoteFile, err := parseLsLine(line)
+		if err != nil {
+			warnings = append(warnings, ListingWarning{Line: line, Err: err})
+			continue
+		}
+		remoteFile.Path = folderPath
+		remoteFile.ParsedDestination = destination
+		files = append(files, remoteFile)
+	}
+	return files, warnings
+}
+
+// Parses line of ls -l --full-time of GNU coreutils or busybox, i.e.
+// "-rw-r--r-- 1 user staff 6 2020-03-17 15:04:05.123456789 +0300 file1.txt". Path is not set. Target of
+// symbolic link "link -> target" is returned in LinkTarget. Device files have "major, minor" instead of size
+// and are returned with zero size
+func parseLsLine(line string) (*models.RemoteFile, error) {
+	fields, name := cutFields(line, lsColumns)
+	if len(fields) < lsColumns || name == "" || len(fields[0]) < 10 {
+		return nil, fmt.Errorf("invalid ls line: %q", line)
+	}
+	if (fields[0][0] == 'b' || fields[0][0] == 'c') && strings.HasSuffix(fields[4], ",") {
+		fields, name = cutFields(line, lsColumns+1)
+		if len(fields) < lsColumns+1 || name == "" {
+			return nil, fmt.Errorf("invalid ls line: %q", line)
+		}
+		fields = append(fields[:4], "0", fields[6], fields[7], fields[8])
+	}
+	size, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid size of ls line: %q", line)
+	}
+	lastmod, err := time.Parse(lsFullTimeLayout, strings.Join(fields[5:8], " "))
+	if err != nil {
+		return nil, fmt.Errorf("invalid time of ls line: %q", line)
+	}
+
+	mode := models.ParseModeString(fields[0])
+	remoteFile := &models.RemoteFile{Name: name, Size: size, Lastmod: lastmod, IsDir: mode.IsDir(),
+		Metadata: &models.FileMetadata{Mode: &mode, Owner: fields[2], Group: fields[3]}}
+	if fields[0][0] == 'l' {
+		if i := strings.Index(name, " -> "); i >= 0 {
+			remoteFile.Name, remoteFile.LinkTarget = name[:i], name[i+len(" -> "):]
+		}
+	}
+	return remoteFile, nil
+}
+
+// Splits n space separated fields and returns them with the rest of line after single separator
+func cutFields(line string, n int) ([]string, string) {
+	var fields []string
+	for len(fields) < n {
+		line = strings.TrimLeft(line, " ")
+		end := strings.IndexByte(line, ' ')
+		if line == "" || end < 0 {
+			return fields, ""
+		}
+		fields = append(fields, line[:end])
+		line = line[end+1:]
+	}
+	return fields, line
+}
@@ -0,0 +1,177 @@
+package scp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Replies of scp protocol. Warnings and errors are followed by message line
+const (
+	scpReplyOk      = 0
+	scpReplyWarning = 1
+	scpReplyError   = 2
+)
+
+// Remote scp process, started with -f (source) or -t (sink)
+type scpSession struct {
+	session *ssh.Session
+	stdin   io.WriteCloser
+	reader  *bufio.Reader
+	stderr  bytes.Buffer
+}
+
+func startScp(client *ssh.Client, command string) (*scpSession, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	scp := &scpSession{session: session}
+	session.Stderr = &scp.stderr
+	if scp.stdin, err = session.StdinPipe(); err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	scp.reader = bufio.NewReader(stdout)
+	if err := session.Start(command); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return scp, nil
+}
+
+// Receives single file from scp -f: "C0644 6 file1.txt\n", content and ok reply
+func (scp *scpSession) receive(w io.Writer) error {
+	if err := scp.ack(); err != nil {
+		return err
+	}
+	line, err := scp.readMessage()
+	if err != nil {
+		return err
+	}
+	//modification times are sent with -p only, but some servers send them anyway
+	if strings.HasPrefix(line, "T") {
+		if err := scp.ack(); err != nil {
+			return err
+		}
+		if line, err = scp.readMessage(); err != nil {
+			return err
+		}
+	}
+	if !strings.HasPrefix(line, "C") {
+		return fmt.Errorf("unexpected scp message: %q", line)
+	}
+	fields := strings.SplitN(line[1:], " ", 3)
+	if len(fields) != 3 {
+		return fmt.Errorf("invalid scp file message: %q", line)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("invalid scp file size: %q", line)
+	}
+
+	if err := scp.ack(); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(w, scp.reader, size); err != nil {
+		return err
+	}
+	if err := scp.readReply(); err != nil {
+		return err
+	}
+	if err := scp.ack(); err != nil {
+		return err
+	}
+	return scp.wait()
+}
+
+// Sends single file to scp -t
+func (scp *scpSession) send(name string, mode os.FileMode, content io.Reader, size int64) error {
+	if err := scp.readReply(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(scp.stdin, "C%04o %d %s\n", mode.Perm(), size, name); err != nil {
+		return err
+	}
+	if err := scp.readReply(); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(scp.stdin, content, size); err != nil {
+		return err
+	}
+	if err := scp.ack(); err != nil {
+		return err
+	}
+	if err := scp.readReply(); err != nil {
+		return err
+	}
+	return scp.wait()
+}
+
+func (scp *scpSession) ack() error {
+	_, err := scp.stdin.Write([]byte{scpReplyOk})
+	return err
+}
+
+// Reads ok reply, or warning or error with message
+func (scp *scpSession) readReply() error {
+	code, err := scp.reader.ReadByte()
+	if err != nil {
+		return scp.exitError(err)
+	}
+	switch code {
+	case scpReplyOk:
+		return nil
+	case scpReplyWarning, scpReplyError:
+		message, _ := scp.reader.ReadString('\n')
+		return errors.New(strings.TrimSpace(message))
+	default:
+		return fmt.Errorf("unexpected scp reply: %d", code)
+	}
+}
+
+// Reads protocol message line. Errors are reported instead of message
+func (scp *scpSession) readMessage() (string, error) {
+	line, err := scp.reader.ReadString('\n')
+	if err != nil {
+		return "", scp.exitError(err)
+	}
+	switch line[0] {
+	case scpReplyWarning, scpReplyError:
+		return "", errors.New(strings.TrimSpace(line[1:]))
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+// Closes stdin, so that scp exits, and waits for exit status
+func (scp *scpSession) wait() error {
+	scp.stdin.Close()
+	if err := scp.session.Wait(); err != nil {
+		return scp.exitError(err)
+	}
+	return nil
+}
+
+// Error of scp, which exited without reply, i.e. if scp is not installed
+func (scp *scpSession) exitError(err error) error {
+	if message := strings.TrimSpace(scp.stderr.String()); message != "" {
+		return fmt.Errorf("scp failed: %v: %s", err, message)
+	}
+	return fmt.Errorf("scp failed: %v", err)
+}
+
+func (scp *scpSession) Close() error {
+	return scp.session.Close()
+}
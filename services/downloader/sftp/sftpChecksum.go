@@ -0,0 +1,331 @@
+package sftp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"github.com/goodsru/go-universal-network-adapter/services/downloader/sshClient"
+	"golang.org/x/crypto/ssh"
+)
+
+//SFTP v3 packet types and status codes used by checksum extensions
+const (
+	sshFxpInit          = 1
+	sshFxpVersion       = 2
+	sshFxpStatus        = 101
+	sshFxpExtended      = 200
+	sshFxpExtendedReply = 201
+
+	sshFxNoSuchFile    = 2
+	sshFxOpUnsupported = 8
+)
+
+//Maximal sftp packet length, as in OpenSSH
+const sftpMaxPacketLength = 256 * 1024
+
+//Algorithm names of check-file extension (draft-ietf-secsh-filexfer-extensions)
+var sftpCheckFileNames = map[models.ChecksumAlgorithm]string{
+	models.ChecksumMD5:    "md5",
+	models.ChecksumSHA1:   "sha1",
+	models.ChecksumSHA256: "sha256",
+	models.ChecksumSHA512: "sha512",
+	models.ChecksumCRC32:  "crc32",
+}
+
+//Commands of exec fallback. Output is "checksum  file name"
+var sftpExecChecksumCommands = map[models.ChecksumAlgorithm]string{
+	models.ChecksumMD5:    "md5sum",
+	models.ChecksumSHA1:   "sha1sum",
+	models.ChecksumSHA256: "sha256sum",
+	models.ChecksumSHA512: "sha512sum",
+}
+
+var errChecksumNotSupported = errors.New("sftp server does not support checksum")
+
+//Status reply of sftp request
+type sftpStatusError struct {
+	code    uint32
+	message string
+}
+
+func (err *sftpStatusError) Error() string {
+	return fmt.Sprintf("sftp status %d: %s", err.code, err.message)
+}
+
+//Get checksum of the file, computed by server with check-file or md5-hash sftp extensions. Servers without them
+//are asked with md5sum, sha1sum, sha256sum or sha512sum, if ExecChecksum is set in sftp options
+func (sftpDownloader *SftpDownloader) Checksum(remoteFile *models.RemoteFile, algorithm models.ChecksumAlgorithm) (string, error) {
+	client, err := sshClient.Dial(remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	filePath := path.Join(remoteFile.Path, remoteFile.Name)
+	checksum, err := extensionChecksum(client, filePath, algorithm)
+	options := remoteFile.ParsedDestination.Sftp
+	if errors.Is(err, errChecksumNotSupported) && options != nil && options.ExecChecksum {
+		checksum, err = execChecksum(client, filePath, algorithm)
+	}
+
+	var statusErr *sftpStatusError
+	if errors.As(err, &statusErr) && statusErr.code == sshFxNoSuchFile {
+		return "", models.NewNotFoundError(remoteFile.ParsedDestination.Url)
+	}
+	if errors.Is(err, errChecksumNotSupported) {
+		return "", fmt.Errorf("%w: %s", err, algorithm)
+	}
+	return checksum, err
+}
+
+func extensionChecksum(client *ssh.Client, filePath string, algorithm models.ChecksumAlgorithm) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return "", err
+	}
+
+	conn, err := newSftpExtensionConn(stdin, stdout)
+	if err != nil {
+		return "", err
+	}
+	return conn.checksum(filePath, algorithm)
+}
+
+//SFTP connection, which sends extended requests only. pkg/sftp does not expose server extensions and extended requests
+type sftpExtensionConn struct {
+	w          io.Writer
+	r          io.Reader
+	extensions map[string]string
+	id         uint32
+}
+
+//Sends SSH_FXP_INIT and reads extensions of SSH_FXP_VERSION
+func newSftpExtensionConn(w io.Writer, r io.Reader) (*sftpExtensionConn, error) {
+	conn := &sftpExtensionConn{w: w, r: r, extensions: map[string]string{}}
+	if err := conn.writePacket(sshFxpInit, appendUint32(nil, 3)); err != nil {
+		return nil, err
+	}
+	packetType, data, err := conn.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if packetType != sshFxpVersion || len(data) < 4 {
+		return nil, fmt.Errorf("invalid sftp version packet of type %d", packetType)
+	}
+	data = data[4:]
+	for len(data) > 0 {
+		var name, value []byte
+		if name, data, err = readString(data); err != nil {
+			return nil, err
+		}
+		if value, data, err = readString(data); err != nil {
+			return nil, err
+		}
+		conn.extensions[string(name)] = string(value)
+	}
+	return conn, nil
+}
+
+//Uses check-file-name extension for any algorithm, and md5-hash for md5. Replies of both extensions start with
+//name of reply: "check-file" with algorithm and hash bytes, "md5-hash" with hash string
+func (conn *sftpExtensionConn) checksum(filePath string, algorithm models.ChecksumAlgorithm) (string, error) {
+	name, ok := sftpCheckFileNames[algorithm]
+	if _, checkFile := conn.extensions["check-file"]; ok && checkFile {
+		args := appendString(nil, filePath)
+		args = appendString(args, name)
+		//whole file in one block: start offset, length and block size are 0
+		args = appendUint64(args, 0)
+		args = appendUint64(args, 0)
+		args = appendUint32(args, 0)
+		reply, err := conn.extended("check-file-name", args)
+		if err != nil {
+			return "", err
+		}
+		reply, err = readReplyName(reply, "check-file")
+		if err != nil {
+			return "", err
+		}
+		used, hash, err := readString(reply)
+		if err != nil {
+			return "", err
+		}
+		if string(used) != name || len(hash) == 0 {
+			return "", fmt.Errorf("invalid sftp check-file reply of algorithm %s", used)
+		}
+		return hex.EncodeToString(hash), nil
+	}
+
+	if _, md5Hash := conn.extensions["md5-hash"]; algorithm == models.ChecksumMD5 && md5Hash {
+		args := appendString(nil, filePath)
+		args = appendUint64(args, 0)
+		args = appendUint64(args, 0)
+		//empty quick check hash
+		args = appendString(args, "")
+		reply, err := conn.extended("md5-hash", args)
+		if err != nil {
+			return "", err
+		}
+		reply, err = readReplyName(reply, "md5-hash")
+		if err != nil {
+			return "", err
+		}
+		hash, _, err := readString(reply)
+		if err != nil {
+			return "", err
+		}
+		if len(hash) != 16 {
+			return "", errors.New("invalid sftp md5-hash reply")
+		}
+		return hex.EncodeToString(hash), nil
+	}
+	return "", errChecksumNotSupported
+}
+
+//Sends SSH_FXP_EXTENDED request and returns data of SSH_FXP_EXTENDED_REPLY
+func (conn *sftpExtensionConn) extended(request string, args []byte) ([]byte, error) {
+	conn.id++
+	data := appendUint32(nil, conn.id)
+	data = appendString(data, request)
+	if err := conn.writePacket(sshFxpExtended, append(data, args...)); err != nil {
+		return nil, err
+	}
+
+	packetType, data, err := conn.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 || binary.BigEndian.Uint32(data) != conn.id {
+		return nil, fmt.Errorf("invalid sftp reply to %s", request)
+	}
+	data = data[4:]
+	switch packetType {
+	case sshFxpExtendedReply:
+		return data, nil
+	case sshFxpStatus:
+		if len(data) < 4 {
+			return nil, fmt.Errorf("invalid sftp status reply to %s", request)
+		}
+		statusErr := &sftpStatusError{code: binary.BigEndian.Uint32(data)}
+		if message, _, err := readString(data[4:]); err == nil {
+			statusErr.message = string(message)
+		}
+		if statusErr.code == sshFxOpUnsupported {
+			return nil, errChecksumNotSupported
+		}
+		return nil, statusErr
+	default:
+		return nil, fmt.Errorf("unexpected sftp packet of type %d in reply to %s", packetType, request)
+	}
+}
+
+//Checks name, which extended reply data starts with, and returns the rest of data
+func readReplyName(reply []byte, name string) ([]byte, error) {
+	replyName, rest, err := readString(reply)
+	if err != nil {
+		return nil, err
+	}
+	if string(replyName) != name {
+		return nil, fmt.Errorf("unexpected sftp reply %q, expected %q", replyName, name)
+	}
+	return rest, nil
+}
+
+func (conn *sftpExtensionConn) writePacket(packetType byte, data []byte) error {
+	packet := appendUint32(nil, uint32(len(data)+1))
+	packet = append(packet, packetType)
+	_, err := conn.w.Write(append(packet, data...))
+	return err
+}
+
+func (conn *sftpExtensionConn) readPacket() (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(conn.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > sftpMaxPacketLength {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(conn.r, packet); err != nil {
+		return 0, nil, err
+	}
+	return packet[0], packet[1:], nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+func readString(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, errors.New("short sftp packet")
+	}
+	length := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < length {
+		return nil, nil, errors.New("short sftp packet")
+	}
+	return b[4 : 4+length], b[4+length:], nil
+}
+
+//Runs md5sum, sha1sum, sha256sum or sha512sum on server
+func execChecksum(client *ssh.Client, filePath string, algorithm models.ChecksumAlgorithm) (string, error) {
+	command, ok := sftpExecChecksumCommands[algorithm]
+	if !ok {
+		return "", errChecksumNotSupported
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	output, err := session.Output(command + " -- " + sshClient.Quote(filePath))
+	if err != nil {
+		if strings.Contains(stderr.String(), "No such file") {
+			return "", &sftpStatusError{code: sshFxNoSuchFile, message: strings.TrimSpace(stderr.String())}
+		}
+		return "", fmt.Errorf("%s failed: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty %s output", command)
+	}
+	//file names with backslash or new line are escaped with leading backslash
+	checksum := strings.ToLower(strings.TrimPrefix(fields[0], "\\"))
+	if _, err := hex.DecodeString(checksum); err != nil || checksum == "" {
+		return "", fmt.Errorf("invalid %s output: %s", command, output)
+	}
+	return checksum, nil
+}
@@ -5,14 +5,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net"
 	"os"
 	"path"
 	"time"
 
 	"github.com/goodsru/go-universal-network-adapter/models"
+	"github.com/goodsru/go-universal-network-adapter/services/downloader/sshClient"
 	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
 )
 
 // sftp file downloader implementation
@@ -65,7 +64,30 @@ func (sftpDownloader *SftpDownloader) stat(client iSftpClient, destination *mode
 		return nil, fmt.Errorf("destination is a directory")
 	}
 
-	return &models.RemoteFile{Name: stat.Name(), Path: filePath, Size: stat.Size(), Lastmod: stat.ModTime(), IsDir: stat.IsDir(), ParsedDestination: destination}, nil
+	return &models.RemoteFile{Name: stat.Name(), Path: filePath, Size: stat.Size(), Lastmod: stat.ModTime(), IsDir: stat.IsDir(),
+		Metadata: fileMetadata(stat), ParsedDestination: destination}, nil
+}
+
+//Metadata of sftp file attributes. SFTP v3 has numeric owner and group only
+func fileMetadata(info os.FileInfo) *models.FileMetadata {
+	mode := info.Mode()
+	metadata := &models.FileMetadata{Mode: &mode}
+	stat, ok := info.Sys().(*sftp.FileStat)
+	if !ok {
+		return metadata
+	}
+	uid, gid := stat.UID, stat.GID
+	metadata.Uid, metadata.Gid = &uid, &gid
+	if stat.Atime != 0 {
+		metadata.Atime = time.Unix(int64(stat.Atime), 0)
+	}
+	if len(stat.Extended) > 0 {
+		metadata.Facts = make(map[string]string, len(stat.Extended))
+		for _, extended := range stat.Extended {
+			metadata.Facts[extended.ExtType] = extended.ExtData
+		}
+	}
+	return metadata
 }
 
 func (sftpDownloader *SftpDownloader) browse(client iSftpClient, destination *models.ParsedDestination) ([]*models.RemoteFile, error) {
@@ -76,16 +98,39 @@ func (sftpDownloader *SftpDownloader) browse(client iSftpClient, destination *mo
 	if err != nil {
 		return nil, err
 	}
+	followSymlinks := destination.Sftp != nil && destination.Sftp.FollowSymlinks
 	for _, item := range items {
-		if item.IsDir() {
-			continue
+		remoteFile := &models.RemoteFile{Name: item.Name(), Path: folderPath, Size: item.Size(), Lastmod: item.ModTime(), IsDir: item.IsDir(),
+			Metadata: fileMetadata(item), ParsedDestination: destination}
+		if item.Mode()&os.ModeSymlink != 0 {
+			if err := sftpDownloader.readLink(client, remoteFile, followSymlinks); err != nil {
+				return nil, err
+			}
 		}
-		result = append(result, &models.RemoteFile{Name: item.Name(), Path: folderPath, Size: item.Size(), Lastmod: item.ModTime(), IsDir: item.IsDir(), ParsedDestination: destination})
+		result = append(result, remoteFile)
 	}
 
 	return result, nil
 }
 
+//Sets LinkTarget of symbolic link and, if follow is set, attributes of its target
+func (sftpDownloader *SftpDownloader) readLink(client iSftpClient, remoteFile *models.RemoteFile, follow bool) error {
+	linkPath := path.Join(remoteFile.Path, remoteFile.Name)
+	target, err := client.ReadLink(linkPath)
+	if err != nil {
+		return err
+	}
+	if follow {
+		//link with missing target keeps its own attributes
+		if info, err := client.Stat(linkPath); err == nil {
+			remoteFile.Size, remoteFile.Lastmod, remoteFile.IsDir = info.Size(), info.ModTime(), info.IsDir()
+			remoteFile.Metadata = fileMetadata(info)
+		}
+	}
+	remoteFile.LinkTarget = target
+	return nil
+}
+
 func (sftpDownloader *SftpDownloader) download(sftpClient iSftpClient, remoteFile *models.RemoteFile) (*models.RemoteFileContent, error) {
 	ftpFile, err := sftpClient.Open(path.Join(remoteFile.Path, remoteFile.Name))
 	if err != nil {
@@ -115,80 +160,100 @@ func (sftpDownloader *SftpDownloader) download(sftpClient iSftpClient, remoteFil
 }
 
 func (sftpDownloader *SftpDownloader) getClient(destination *models.ParsedDestination) (iSftpClient, error) {
-	user := destination.GetUser()
-	pass := destination.GetPassword()
-
-	var auth []ssh.AuthMethod
-
-	if pass != "" {
-		auth = append(auth, ssh.Password(pass))
-	}
-
-	if signer, err := destination.GetRsaPrivateKey(); err == nil {
-		auth = append(auth, ssh.PublicKeys(signer))
-	}
-
-	sshConfig := &ssh.ClientConfig{
-		User:            user,
-		Auth:            auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         destination.Timeout,
-	}
-
-	url := destination.GetHost()
-	client, err := sftpDownloader.sshDialWithTimeout("tcp", url, sshConfig, sshConfig.Timeout)
+	client, err := sshClient.Dial(destination)
 	if err != nil {
 		return nil, err
 	}
 
 	sftpClient, err := sftp.NewClient(client)
 	if err != nil {
+		client.Close()
 		return nil, err
 	}
 
 	return &sftpClientWrapper{sftpClient}, nil
 }
 
-// ssh.Dial can hang during authentication, the 'timeout' being set in the config only applying to establishment of the initial connection.
-// This function is effectively ssh.Dial with the ability to set a deadline on the underlying connection.
-// https://github.com/Yeba/fuchsia-tool/commit/652b0acfd634aea432eb2432dcc8ea5a37dccc3b?diff=split
-func (sftpDownloader *SftpDownloader) sshDialWithTimeout(network, addr string, config *ssh.ClientConfig, hardTimeout time.Duration) (*ssh.Client, error) {
-	conn, err := net.DialTimeout(network, addr, config.Timeout)
+func (sftpDownloader *SftpDownloader) remove(client iSftpClient, remoteFile *models.RemoteFile) error {
+	filePath := path.Join(remoteFile.Path, remoteFile.Name)
+	stat, err := client.Stat(filePath)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err := conn.SetDeadline(time.Now().Add(hardTimeout)); err != nil {
-		conn.Close()
-		return nil, err
+	if stat.IsDir() {
+		return client.RemoveDirectory(filePath)
 	}
-	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	return client.Remove(filePath)
+}
+
+//Removes file, or directory with all files and subdirectories depth-first. Symbolic links are removed, not followed.
+//With options.DryRun nothing is removed. Returns removed paths in deletion order, including the ones removed before
+//an error
+func (sftpDownloader *SftpDownloader) RemoveRecursive(remoteFile *models.RemoteFile, options *models.RemoveOptions) ([]string, error) {
+	sftpClient, err := sftpDownloader.getClient(remoteFile.ParsedDestination)
 	if err != nil {
-		conn.Close()
 		return nil, err
 	}
-	if err := conn.SetDeadline(time.Time{}); err != nil {
-		c.Close()
+	defer sftpClient.Close()
+	return sftpDownloader.removeRecursive(sftpClient, remoteFile, options)
+}
+
+//Walks directory tree and removes files before their directories
+type sftpTreeRemover struct {
+	client  iSftpClient
+	dryRun  bool
+	removed []string
+}
+
+func (sftpDownloader *SftpDownloader) removeRecursive(client iSftpClient, remoteFile *models.RemoteFile, options *models.RemoveOptions) ([]string, error) {
+	if options == nil {
+		options = &models.RemoveOptions{}
+	}
+	filePath := path.Join(remoteFile.Path, remoteFile.Name)
+	stat, err := client.Lstat(filePath)
+	if err != nil {
 		return nil, err
 	}
-	return ssh.NewClient(c, chans, reqs), nil
+	remover := &sftpTreeRemover{client: client, dryRun: options.DryRun, removed: make([]string, 0)}
+	err = remover.remove(filePath, stat)
+	return remover.removed, err
 }
 
-func (sftpDownloader *SftpDownloader) remove(client iSftpClient, remoteFile *models.RemoteFile) error {
-	filePath := path.Join(remoteFile.Path, remoteFile.Name)
-	stat, err := client.Stat(filePath)
+func (remover *sftpTreeRemover) remove(filePath string, info os.FileInfo) error {
+	if !info.IsDir() {
+		if !remover.dryRun {
+			if err := remover.client.Remove(filePath); err != nil {
+				return err
+			}
+		}
+		remover.removed = append(remover.removed, filePath)
+		return nil
+	}
+
+	items, err := remover.client.ReadDir(filePath)
 	if err != nil {
 		return err
 	}
-	if stat.IsDir() {
-		return client.RemoveDirectory(filePath)
+	for _, item := range items {
+		if err := remover.remove(path.Join(filePath, item.Name()), item); err != nil {
+			return err
+		}
 	}
-	return client.Remove(filePath)
+	if !remover.dryRun {
+		if err := remover.client.RemoveDirectory(filePath); err != nil {
+			return err
+		}
+	}
+	remover.removed = append(remover.removed, filePath)
+	return nil
 }
 
 type iSftpClient interface {
 	ReadDir(root string) ([]os.FileInfo, error)
 	Open(path string) (io.ReadCloser, error)
 	Stat(p string) (os.FileInfo, error)
+	Lstat(p string) (os.FileInfo, error)
+	ReadLink(p string) (string, error)
 	Remove(path string) error
 	RemoveDirectory(path string) error
 	Close() error
@@ -50,6 +50,19 @@ func (ftp *fakeSftpClient) Close() error {
 	return args.Error(0)
 }
 
+func (ftp *fakeSftpClient) Lstat(p string) (os.FileInfo, error) {
+	args := ftp.Called(p)
+	if args.Get(0) != nil {
+		return args.Get(0).(os.FileInfo), args.Error(1)
+	}
+	return (os.FileInfo)(nil), args.Error(1)
+}
+
+func (ftp *fakeSftpClient) ReadLink(p string) (string, error) {
+	args := ftp.Called(p)
+	return args.String(0), args.Error(1)
+}
+
 func (ftp *fakeSftpClient) Stat(p string) (os.FileInfo, error) {
 	args := ftp.Called(p)
 	if args.Get(0) != nil {
@@ -60,6 +73,7 @@ func (ftp *fakeSftpClient) Stat(p string) (os.FileInfo, error) {
 
 type fakeFileInfo struct {
 	mock.Mock
+	mode os.FileMode
 }
 
 func (f *fakeFileInfo) Name() string {
@@ -80,7 +94,7 @@ func (f *fakeFileInfo) Size() int64 {
 	return args.Get(0).(int64)
 }
 func (f *fakeFileInfo) Mode() os.FileMode {
-	return 0777
+	return f.mode | 0777
 }
 func (f *fakeFileInfo) Sys() interface{} {
 	return nil
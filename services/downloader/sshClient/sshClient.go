@@ -0,0 +1,72 @@
+// Package connects ssh clients of sftp and scp downloaders
+package sshClient
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/goodsru/go-universal-network-adapter/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// Port of ssh servers, if URL has no port
+const defaultPort = "22"
+
+// Connects to host of destination and authenticates with password and RSA private key of credentials
+func Dial(destination *models.ParsedDestination) (*ssh.Client, error) {
+	user := destination.GetUser()
+	pass := destination.GetPassword()
+
+	var auth []ssh.AuthMethod
+
+	if pass != "" {
+		auth = append(auth, ssh.Password(pass))
+	}
+
+	if signer, err := destination.GetRsaPrivateKey(); err == nil {
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         destination.Timeout,
+	}
+
+	url := destination.GetHost()
+	if destination.ParsedUrl.Port() == "" {
+		url = net.JoinHostPort(destination.ParsedUrl.Hostname(), defaultPort)
+	}
+	return dialWithTimeout("tcp", url, sshConfig, sshConfig.Timeout)
+}
+
+// ssh.Dial can hang during authentication, the 'timeout' being set in the config only applying to establishment of the initial connection.
+// This function is effectively ssh.Dial with the ability to set a deadline on the underlying connection.
+// https://github.com/Yeba/fuchsia-tool/commit/652b0acfd634aea432eb2432dcc8ea5a37dccc3b?diff=split
+func dialWithTimeout(network, addr string, config *ssh.ClientConfig, hardTimeout time.Duration) (*ssh.Client, error) {
+	conn, err := net.DialTimeout(network, addr, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(hardTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// Quotes argument of exec command for POSIX shell
+func Quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
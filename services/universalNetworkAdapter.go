@@ -2,12 +2,14 @@ package services
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/goodsru/go-universal-network-adapter/contracts"
 	"github.com/goodsru/go-universal-network-adapter/models"
 	"github.com/goodsru/go-universal-network-adapter/services/downloader/ftp"
 	"github.com/goodsru/go-universal-network-adapter/services/downloader/http"
 	"github.com/goodsru/go-universal-network-adapter/services/downloader/s3"
+	"github.com/goodsru/go-universal-network-adapter/services/downloader/scp"
 	"github.com/goodsru/go-universal-network-adapter/services/downloader/sftp"
 )
 
@@ -17,6 +19,7 @@ const (
 	FTP   = "ftp"
 	FTPS  = "ftps"
 	SFTP  = "sftp"
+	SCP   = "scp"
 	S3    = "s3"
 )
 
@@ -34,6 +37,7 @@ func NewUniversalNetworkAdapter() *UniversalNetworkAdapter {
 	adapter.RegisterDownloader(&ftp.FtpDownloader{}, FTP)
 	adapter.RegisterDownloader(&ftp.FtpDownloader{}, FTPS)
 	adapter.RegisterDownloader(&sftp.SftpDownloader{}, SFTP)
+	adapter.RegisterDownloader(&scp.ScpDownloader{}, SCP)
 
 	adapter.RegisterDownloader(&s3.S3Downloader{}, S3)
 
@@ -80,6 +84,70 @@ func (adapter *UniversalNetworkAdapter) Remove(remoteFile *models.RemoteFile) er
 	return downloader.Remove(remoteFile)
 }
 
+func (adapter *UniversalNetworkAdapter) RemoveRecursive(remoteFile *models.RemoteFile, options *models.RemoveOptions) ([]string, error) {
+	downloader, err := adapter.getDownloader(remoteFile.ParsedDestination)
+	if err != nil {
+		return nil, err
+	}
+	remover, ok := downloader.(contracts.RecursiveRemover)
+	if !ok {
+		return nil, fmt.Errorf("загрузчик для URL: %v не поддерживает рекурсивное удаление", remoteFile.ParsedDestination.Url)
+	}
+	return remover.RemoveRecursive(remoteFile, options)
+}
+
+func (adapter *UniversalNetworkAdapter) Checksum(remoteFile *models.RemoteFile, algorithm models.ChecksumAlgorithm) (string, error) {
+	downloader, err := adapter.getDownloader(remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+	checksummer, ok := downloader.(contracts.Checksummer)
+	if !ok {
+		return "", fmt.Errorf("загрузчик для URL: %v не поддерживает контрольные суммы", remoteFile.ParsedDestination.Url)
+	}
+	return checksummer.Checksum(remoteFile, algorithm)
+}
+
+func (adapter *UniversalNetworkAdapter) Upload(remoteFile *models.RemoteFile, content io.Reader, size int64) error {
+	downloader, err := adapter.getDownloader(remoteFile.ParsedDestination)
+	if err != nil {
+		return err
+	}
+	uploader, ok := downloader.(contracts.Uploader)
+	if !ok {
+		return fmt.Errorf("загрузчик для URL: %v не поддерживает загрузку файлов на сервер", remoteFile.ParsedDestination.Url)
+	}
+	return uploader.Upload(remoteFile, content, size)
+}
+
+func (adapter *UniversalNetworkAdapter) PresignGet(remoteFile *models.RemoteFile, options *models.PresignOptions) (string, error) {
+	presigner, err := adapter.getPresigner(remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+	return presigner.PresignGet(remoteFile, options)
+}
+
+func (adapter *UniversalNetworkAdapter) PresignPut(remoteFile *models.RemoteFile, options *models.PresignOptions) (string, error) {
+	presigner, err := adapter.getPresigner(remoteFile.ParsedDestination)
+	if err != nil {
+		return "", err
+	}
+	return presigner.PresignPut(remoteFile, options)
+}
+
+func (adapter *UniversalNetworkAdapter) getPresigner(parsedDestination *models.ParsedDestination) (contracts.Presigner, error) {
+	downloader, err := adapter.getDownloader(parsedDestination)
+	if err != nil {
+		return nil, err
+	}
+	presigner, ok := downloader.(contracts.Presigner)
+	if !ok {
+		return nil, fmt.Errorf("загрузчик для URL: %v не поддерживает подписанные ссылки", parsedDestination.Url)
+	}
+	return presigner, nil
+}
+
 func (adapter *UniversalNetworkAdapter) getDownloader(parsedDestination *models.ParsedDestination) (contracts.Downloader, error) {
 	var scheme string
 
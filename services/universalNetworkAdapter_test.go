@@ -2,12 +2,15 @@ package services
 
 import (
 	"io"
+	"io/ioutil"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/goodsru/go-universal-network-adapter/contracts"
 	"github.com/goodsru/go-universal-network-adapter/models"
 	"github.com/goodsru/go-universal-network-adapter/services/downloader"
+	"github.com/goodsru/go-universal-network-adapter/tests/s3Server"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -302,3 +305,78 @@ func TestUniversalNetworkAdapter_HTTPSDownloader(t *testing.T) {
 	})
 
 }
+
+func TestUniversalNetworkAdapter_S3Presign(t *testing.T) {
+	data := `{"status": "ok"}`
+	server := s3Server.NewTestS3Server()
+	defer server.Close()
+	server.PutObject("bucket", "files/report.json", []byte(data), time.Now())
+	adapter := NewUniversalNetworkAdapter()
+	remoteFile, _ := models.NewRemoteFile(&models.Destination{Url: "s3://bucket/files/report.json",
+		S3: &models.S3Options{Endpoint: server.URL}, Credentials: &models.Credentials{User: "AKIDEXAMPLE", Password: "secret"}})
+
+	t.Run("PresignGetS3_DownloadsWithHttpDownloader", func(t *testing.T) {
+		presignedUrl, err := adapter.PresignGet(remoteFile, &models.PresignOptions{Expires: time.Hour,
+			ResponseContentDisposition: `attachment; filename="stocks.json"`})
+		assert.Nil(t, err, "err ожидается - nil")
+
+		httpFile, err := adapter.Stat(&models.Destination{Url: presignedUrl})
+		assert.Nil(t, err, "err ожидается - nil")
+		assert.Equal(t, "stocks.json", httpFile.Name, "Ожидаем имя файла из Content-Disposition")
+		assert.Equal(t, int64(len(data)), httpFile.Size)
+
+		content, err := adapter.Download(httpFile)
+		assert.Nil(t, err, "err ожидается - nil")
+		body, err := ioutil.ReadAll(content.Blob)
+		assert.Nil(t, err, "err ожидается - nil")
+		assert.Equal(t, data, string(body))
+		assert.Nil(t, content.Blob.Close())
+	})
+
+	t.Run("PresignFtp_ReturnsError", func(t *testing.T) {
+		ftpFile, _ := models.NewRemoteFile(&models.Destination{Url: "ftp://goods.ru/file.txt"})
+		_, err := adapter.PresignGet(ftpFile, nil)
+		assert.NotNil(t, err, "err ожидается - не nil")
+		_, err = adapter.PresignPut(ftpFile, nil)
+		assert.NotNil(t, err, "err ожидается - не nil")
+	})
+}
+
+func TestUniversalNetworkAdapter_RemoveRecursive(t *testing.T) {
+	adapter := NewUniversalNetworkAdapter()
+
+	t.Run("RemoveRecursiveHttp_ReturnsError", func(t *testing.T) {
+		httpFile, _ := models.NewRemoteFile(&models.Destination{Url: "http://goods.ru/files"})
+		removed, err := adapter.RemoveRecursive(httpFile, &models.RemoveOptions{DryRun: true})
+		assert.NotNil(t, err, "err ожидается - не nil")
+		assert.Nil(t, removed, "removed ожидается - nil")
+	})
+}
+
+func TestUniversalNetworkAdapter_Checksum(t *testing.T) {
+	adapter := NewUniversalNetworkAdapter()
+
+	t.Run("ChecksumHttp_ReturnsError", func(t *testing.T) {
+		httpFile, _ := models.NewRemoteFile(&models.Destination{Url: "http://goods.ru/files/file.txt"})
+		checksum, err := adapter.Checksum(httpFile, models.ChecksumMD5)
+		assert.NotNil(t, err, "err ожидается - не nil")
+		assert.Equal(t, "", checksum)
+	})
+}
+
+func TestUniversalNetworkAdapter_Upload(t *testing.T) {
+	adapter := NewUniversalNetworkAdapter()
+
+	t.Run("UploadHttp_ReturnsError", func(t *testing.T) {
+		httpFile, _ := models.NewRemoteFile(&models.Destination{Url: "http://goods.ru/files/file.txt"})
+		err := adapter.Upload(httpFile, strings.NewReader("Privet"), 6)
+		assert.NotNil(t, err, "err ожидается - не nil")
+	})
+
+	t.Run("ScpDownloader_IsRegistered", func(t *testing.T) {
+		scpFile, _ := models.NewRemoteFile(&models.Destination{Url: "scp://goods.ru/files/file.txt"})
+		downloader, err := adapter.getDownloader(scpFile.ParsedDestination)
+		assert.Nil(t, err, "err ожидается - nil")
+		assert.Implements(t, (*contracts.Uploader)(nil), downloader)
+	})
+}
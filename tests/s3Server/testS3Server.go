@@ -0,0 +1,739 @@
+// Package provides in-memory S3 compatible server for tests
+package s3Server
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object stored in test server
+type Object struct {
+	Key          string
+	Data         []byte
+	LastModified time.Time
+	ContentType  string
+	ETag         string
+	// user metadata without x-amz-meta- prefix
+	Metadata     map[string]string
+	StorageClass string
+	VersionId    string
+	// server-side encryption headers (x-amz-server-side-encryption*) returned with object
+	EncryptionHeaders map[string]string
+	// object can not be deleted, like with object lock
+	Locked bool
+	// version is a delete marker without data
+	DeleteMarker bool
+	// base64 md5 of SSE-C customer key. Object can be read only with this key, if set
+	CustomerKeyMD5 string
+	// base64 checksums returned with x-amz-checksum-mode: ENABLED for requests without Range
+	ChecksumCRC32C string
+	ChecksumSHA256 string
+}
+
+// Request received by test server
+type Request struct {
+	Method string
+	Host   string
+	Bucket string
+	Key    string
+	Query  map[string][]string
+	Header http.Header
+}
+
+// Server is an in-memory S3 compatible server. Supports path-style and virtual-hosted requests
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	requests []Request
+	// version id sequence
+	versionSeq int
+}
+
+type bucket struct {
+	// latest versions by key
+	objects map[string]*Object
+	// all versions and delete markers by key, newest first. Versioned buckets only
+	versions   map[string][]*Object
+	versioning bool
+}
+
+// NewTestS3Server starts test server on random local port
+func NewTestS3Server() *Server {
+	server := &Server{buckets: make(map[string]*bucket)}
+	server.Server = httptest.NewServer(server)
+	return server
+}
+
+// NewTestS3TLSServer starts https test server with self-signed certificate. SSE-C requests require https
+func NewTestS3TLSServer() *Server {
+	server := &Server{buckets: make(map[string]*bucket)}
+	server.Server = httptest.NewTLSServer(server)
+	return server
+}
+
+// CustomerKeyMD5 returns value of x-amz-server-side-encryption-customer-key-md5 header for SSE-C key
+func CustomerKeyMD5(key []byte) string {
+	sum := md5.Sum(key)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ChecksumCRC32C returns value of x-amz-checksum-crc32c header for data
+func ChecksumCRC32C(data []byte) string {
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+// ChecksumSHA256 returns value of x-amz-checksum-sha256 header for data
+func ChecksumSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// CreateBucket creates empty bucket
+func (server *Server) CreateBucket(name string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.getOrCreateBucket(name)
+}
+
+// EnableVersioning creates bucket, if needed, and enables versioning on it
+func (server *Server) EnableVersioning(name string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.getOrCreateBucket(name).versioning = true
+}
+
+func (server *Server) getOrCreateBucket(name string) *bucket {
+	b, ok := server.buckets[name]
+	if !ok {
+		b = &bucket{objects: make(map[string]*Object), versions: make(map[string][]*Object)}
+		server.buckets[name] = b
+	}
+	return b
+}
+
+// PutObject stores object, creating bucket if needed. Object gets new VersionId in versioned bucket
+func (server *Server) PutObject(bucket, key string, data []byte, lastModified time.Time) *Object {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	sum := md5.Sum(data)
+	object := &Object{Key: key, Data: data, LastModified: lastModified.UTC().Truncate(time.Second),
+		ContentType: "application/octet-stream", ETag: `"` + hex.EncodeToString(sum[:]) + `"`}
+	server.store(server.getOrCreateBucket(bucket), object)
+	return object
+}
+
+// GetObject returns the latest version of stored object or nil
+func (server *Server) GetObject(bucket, key string) *Object {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if b, ok := server.buckets[bucket]; ok {
+		return b.objects[key]
+	}
+	return nil
+}
+
+// Versions returns all versions and delete markers of key, newest first
+func (server *Server) Versions(bucket, key string) []*Object {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if b, ok := server.buckets[bucket]; ok {
+		return append([]*Object{}, b.versions[key]...)
+	}
+	return nil
+}
+
+// Stores object as the latest version. Must be called under lock
+func (server *Server) store(b *bucket, object *Object) {
+	if b.versioning {
+		server.versionSeq++
+		object.VersionId = fmt.Sprintf("v%06d", server.versionSeq)
+		b.versions[object.Key] = append([]*Object{object}, b.versions[object.Key]...)
+	}
+	if object.DeleteMarker {
+		delete(b.objects, object.Key)
+	} else {
+		b.objects[object.Key] = object
+	}
+}
+
+// Returns version of key or the latest version for empty versionId. Must be called under lock
+func (b *bucket) find(key, versionId string) *Object {
+	if versionId == "" {
+		return b.objects[key]
+	}
+	for _, version := range b.versions[key] {
+		if version.VersionId == versionId {
+			return version
+		}
+	}
+	return nil
+}
+
+// Deletes key or its version. Deleting the latest version of versioned bucket adds delete marker.
+// Returns error code for locked objects. Must be called under lock
+func (server *Server) delete(b *bucket, key, versionId string) string {
+	object := b.find(key, versionId)
+	if object != nil && object.Locked {
+		return "AccessDenied"
+	}
+	switch {
+	case versionId != "":
+		versions := b.versions[key][:0]
+		for _, version := range b.versions[key] {
+			if version.VersionId != versionId {
+				versions = append(versions, version)
+			}
+		}
+		b.versions[key] = versions
+		delete(b.objects, key)
+		if len(versions) > 0 && !versions[0].DeleteMarker {
+			b.objects[key] = versions[0]
+		}
+	case b.versioning:
+		server.store(b, &Object{Key: key, DeleteMarker: true, LastModified: time.Now().UTC()})
+	default:
+		delete(b.objects, key)
+	}
+	return ""
+}
+
+// Requests returns all requests received by server
+func (server *Server) Requests() []Request {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return append([]Request{}, server.requests...)
+}
+
+// ResetRequests clears received requests
+func (server *Server) ResetRequests() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.requests = nil
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, key := server.route(r)
+	query := r.URL.Query()
+	server.mu.Lock()
+	server.requests = append(server.requests, Request{Method: r.Method, Host: r.Host, Bucket: name, Key: key,
+		Query: query, Header: r.Header.Clone()})
+	b, ok := server.buckets[name]
+	server.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	if presignExpired(query) {
+		writeError(w, http.StatusForbidden, "AccessDenied", "Request has expired")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == "GET" && query["versions"] != nil:
+		server.listVersions(w, r, name, b)
+	case key == "" && r.Method == "GET":
+		server.listObjects(w, r, name, b.objects)
+	case key != "" && (r.Method == "GET" || r.Method == "HEAD"):
+		server.mu.Lock()
+		object := b.find(key, query.Get("versionId"))
+		server.mu.Unlock()
+		switch {
+		case object == nil && query.Get("versionId") != "":
+			writeError(w, http.StatusNotFound, "NoSuchVersion", "The specified version does not exist.")
+		case object == nil:
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		case object.DeleteMarker:
+			w.Header().Set("X-Amz-Delete-Marker", "true")
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+		case !checkCustomerKey(w, r.Header, "X-Amz-Server-Side-Encryption-Customer-", object):
+		case r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != object.ETag:
+			writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+		default:
+			serveObject(w, r, object)
+		}
+	case key != "" && r.Method == "PUT" && r.Header.Get("X-Amz-Copy-Source") != "":
+		server.copyObject(w, r, b, key)
+	case key != "" && r.Method == "PUT":
+		server.putObject(w, r, b, key)
+	case key == "" && r.Method == "POST" && query["delete"] != nil:
+		server.deleteObjects(w, r, b)
+	case key != "" && r.Method == "DELETE":
+		server.mu.Lock()
+		code := server.delete(b, key, query.Get("versionId"))
+		server.mu.Unlock()
+		if code != "" {
+			writeError(w, http.StatusForbidden, code, "Access Denied")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "Operation is not supported by test server")
+	}
+}
+
+// Checks expiration of presigned SigV4 (X-Amz-Date and X-Amz-Expires) and SigV2 (Expires) urls.
+// Signatures are not verified
+func presignExpired(query url.Values) bool {
+	now := time.Now().UTC()
+	if query.Get("X-Amz-Expires") != "" {
+		date, err := time.Parse("20060102T150405Z", query.Get("X-Amz-Date"))
+		seconds, convErr := strconv.Atoi(query.Get("X-Amz-Expires"))
+		return err != nil || convErr != nil || now.After(date.Add(time.Duration(seconds)*time.Second))
+	}
+	if query.Get("Signature") != "" {
+		expires, err := strconv.ParseInt(query.Get("Expires"), 10, 64)
+		return err != nil || now.Unix() > expires
+	}
+	return false
+}
+
+// PutObject with data of request body, content type and user metadata
+func (server *Server) putObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	sum := md5.Sum(data)
+	object := &Object{Key: key, Data: data, LastModified: time.Now().UTC().Truncate(time.Second),
+		ContentType: r.Header.Get("Content-Type"), ETag: `"` + hex.EncodeToString(sum[:]) + `"`}
+	if object.ContentType == "" {
+		object.ContentType = "application/octet-stream"
+	}
+	object.ChecksumCRC32C = r.Header.Get("X-Amz-Checksum-Crc32c")
+	object.ChecksumSHA256 = r.Header.Get("X-Amz-Checksum-Sha256")
+	object.EncryptionHeaders = encryptionHeaders(r.Header)
+	for name := range r.Header {
+		if strings.HasPrefix(name, "X-Amz-Meta-") {
+			if object.Metadata == nil {
+				object.Metadata = make(map[string]string)
+			}
+			object.Metadata[strings.ToLower(strings.TrimPrefix(name, "X-Amz-Meta-"))] = r.Header.Get(name)
+		}
+	}
+
+	server.mu.Lock()
+	server.store(b, object)
+	server.mu.Unlock()
+	if object.VersionId != "" {
+		w.Header().Set("X-Amz-Version-Id", object.VersionId)
+	}
+	w.Header().Set("ETag", object.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Returns SSE-S3 and SSE-KMS headers of written object. Nil, if there are none
+func encryptionHeaders(header http.Header) map[string]string {
+	var headers map[string]string
+	for _, name := range []string{"X-Amz-Server-Side-Encryption", "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"} {
+		if value := header.Get(name); value != "" {
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// Returns bucket and key of request. Bucket is taken from host, if its first label is a known bucket
+func (server *Server) route(r *http.Request) (string, string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	host := strings.Split(r.Host, ":")[0]
+	if dot := strings.Index(host, "."); dot > 0 {
+		if _, ok := server.buckets[host[:dot]]; ok {
+			return host[:dot], strings.TrimPrefix(r.URL.Path, "/")
+		}
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+type listContent struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	MaxKeys               int
+	IsTruncated           bool
+	Marker                string `xml:",omitempty"`
+	NextMarker            string `xml:",omitempty"`
+	ContinuationToken     string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	StartAfter            string `xml:",omitempty"`
+	KeyCount              int    `xml:",omitempty"`
+	Contents              []listContent
+	CommonPrefixes        []listPrefix
+}
+
+// ListObjects and ListObjectsV2 with prefix, delimiter and pagination
+func (server *Server) listObjects(w http.ResponseWriter, r *http.Request, bucket string, objects map[string]*Object) {
+	query := r.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	v2 := query.Get("list-type") == "2"
+	maxKeys := 1000
+	if value := query.Get("max-keys"); value != "" {
+		maxKeys, _ = strconv.Atoi(value)
+	}
+	after := query.Get("marker")
+	if v2 {
+		after = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			after = token
+		}
+	}
+
+	server.mu.Lock()
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := listBucketResult{Name: bucket, Prefix: prefix, Delimiter: delimiter, MaxKeys: maxKeys}
+	seenPrefixes := make(map[string]bool)
+	last := ""
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			continue
+		}
+		entry := key
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				entry = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if entry <= after || seenPrefixes[entry] {
+			continue
+		}
+		if len(result.Contents)+len(result.CommonPrefixes) == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		if entry != key {
+			seenPrefixes[entry] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{Prefix: entry})
+		} else {
+			object := objects[key]
+			result.Contents = append(result.Contents, listContent{Key: key, ETag: object.ETag, Size: int64(len(object.Data)),
+				LastModified: object.LastModified.Format("2006-01-02T15:04:05.000Z"), StorageClass: "STANDARD"})
+		}
+		last = entry
+	}
+	server.mu.Unlock()
+
+	if v2 {
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = query.Get("start-after")
+		result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+		if result.IsTruncated {
+			result.NextContinuationToken = last
+		}
+	} else {
+		result.Marker = query.Get("marker")
+		if result.IsTruncated && delimiter != "" {
+			result.NextMarker = last
+		}
+	}
+	writeXml(w, http.StatusOK, result)
+}
+
+type deleteRequest struct {
+	Quiet   bool
+	Objects []struct {
+		Key       string
+		VersionId string
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []struct {
+		Key string
+	}
+	Errors []errorResponse `xml:"Error"`
+}
+
+// DeleteObjects. Locked objects are reported as per-key errors
+func (server *Server) deleteObjects(w http.ResponseWriter, r *http.Request, b *bucket) {
+	var request deleteRequest
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+	if len(request.Objects) > 1000 {
+		writeError(w, http.StatusBadRequest, "MalformedXML", "More than 1000 keys")
+		return
+	}
+
+	result := deleteResult{}
+	server.mu.Lock()
+	for _, object := range request.Objects {
+		if code := server.delete(b, object.Key, object.VersionId); code != "" {
+			result.Errors = append(result.Errors, errorResponse{Key: object.Key, VersionId: object.VersionId, Code: code, Message: "Access Denied"})
+			continue
+		}
+		if !request.Quiet {
+			result.Deleted = append(result.Deleted, struct{ Key string }{object.Key})
+		}
+	}
+	server.mu.Unlock()
+	writeXml(w, http.StatusOK, result)
+}
+
+type listVersionEntry struct {
+	Key          string
+	VersionId    string
+	IsLatest     bool
+	LastModified string
+	ETag         string `xml:",omitempty"`
+	Size         int64  `xml:",omitempty"`
+	StorageClass string `xml:",omitempty"`
+}
+
+type listVersionsResult struct {
+	XMLName       xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListVersionsResult"`
+	Name          string
+	Prefix        string
+	MaxKeys       int
+	IsTruncated   bool
+	Versions      []listVersionEntry `xml:"Version"`
+	DeleteMarkers []listVersionEntry `xml:"DeleteMarker"`
+}
+
+// ListObjectVersions with prefix. Pagination is not supported
+func (server *Server) listVersions(w http.ResponseWriter, r *http.Request, name string, b *bucket) {
+	prefix := r.URL.Query().Get("prefix")
+	result := listVersionsResult{Name: name, Prefix: prefix, MaxKeys: 1000}
+
+	server.mu.Lock()
+	keys := make([]string, 0, len(b.versions))
+	for key := range b.versions {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for i, version := range b.versions[key] {
+			entry := listVersionEntry{Key: key, VersionId: version.VersionId, IsLatest: i == 0,
+				LastModified: version.LastModified.Format("2006-01-02T15:04:05.000Z")}
+			if version.DeleteMarker {
+				result.DeleteMarkers = append(result.DeleteMarkers, entry)
+				continue
+			}
+			entry.ETag, entry.Size, entry.StorageClass = version.ETag, int64(len(version.Data)), "STANDARD"
+			result.Versions = append(result.Versions, entry)
+		}
+	}
+	server.mu.Unlock()
+	writeXml(w, http.StatusOK, result)
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	ETag         string
+	LastModified string
+}
+
+// CopyObject from x-amz-copy-source: bucket/key?versionId=id
+func (server *Server) copyObject(w http.ResponseWriter, r *http.Request, b *bucket, key string) {
+	source, err := url.Parse("/" + strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(source.Path, "/"), "/", 2)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	sourceBucket, ok := server.buckets[parts[0]]
+	var object *Object
+	if ok && len(parts) == 2 {
+		object = sourceBucket.find(parts[1], source.Query().Get("versionId"))
+	}
+	if object == nil || object.DeleteMarker {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+
+	if !checkCustomerKey(w, r.Header, "X-Amz-Copy-Source-Server-Side-Encryption-Customer-", object) {
+		return
+	}
+
+	copied := *object
+	copied.Key = key
+	copied.VersionId = ""
+	copied.Locked = false
+	copied.LastModified = time.Now().UTC()
+	copied.CustomerKeyMD5 = r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5")
+	copied.EncryptionHeaders = encryptionHeaders(r.Header)
+	server.store(b, &copied)
+	if copied.VersionId != "" {
+		w.Header().Set("X-Amz-Version-Id", copied.VersionId)
+	}
+	writeXml(w, http.StatusOK, copyObjectResult{ETag: copied.ETag, LastModified: copied.LastModified.Format("2006-01-02T15:04:05.000Z")})
+}
+
+// Checks SSE-C headers with given prefix against object key. Writes error and returns false on mismatch
+func checkCustomerKey(w http.ResponseWriter, header http.Header, prefix string, object *Object) bool {
+	if object.CustomerKeyMD5 == "" {
+		return true
+	}
+	key, err := base64.StdEncoding.DecodeString(header.Get(prefix + "Key"))
+	if header.Get(prefix+"Algorithm") != "AES256" || err != nil || CustomerKeyMD5(key) != object.CustomerKeyMD5 ||
+		header.Get(prefix+"Key-Md5") != object.CustomerKeyMD5 {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "The object was stored using a form of Server Side Encryption. "+
+			"The correct parameters must be provided to retrieve the object.")
+		return false
+	}
+	return true
+}
+
+// GetObject query parameters, which override response headers
+var responseOverrides = map[string]string{
+	"response-cache-control":       "Cache-Control",
+	"response-content-disposition": "Content-Disposition",
+	"response-content-encoding":    "Content-Encoding",
+	"response-content-language":    "Content-Language",
+	"response-content-type":        "Content-Type",
+	"response-expires":             "Expires",
+}
+
+// Writes object data, respecting single range requests
+func serveObject(w http.ResponseWriter, r *http.Request, object *Object) {
+	w.Header().Set("Content-Type", object.ContentType)
+	w.Header().Set("ETag", object.ETag)
+	w.Header().Set("Last-Modified", object.LastModified.Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+	for name, value := range object.Metadata {
+		w.Header().Set("X-Amz-Meta-"+name, value)
+	}
+	if object.StorageClass != "" && object.StorageClass != "STANDARD" {
+		w.Header().Set("X-Amz-Storage-Class", object.StorageClass)
+	}
+	if object.VersionId != "" {
+		w.Header().Set("X-Amz-Version-Id", object.VersionId)
+	}
+	for name, value := range object.EncryptionHeaders {
+		w.Header().Set(name, value)
+	}
+	if object.CustomerKeyMD5 != "" {
+		w.Header().Set("X-Amz-Server-Side-Encryption-Customer-Algorithm", "AES256")
+		w.Header().Set("X-Amz-Server-Side-Encryption-Customer-Key-Md5", object.CustomerKeyMD5)
+	}
+	if r.Header.Get("X-Amz-Checksum-Mode") == "ENABLED" && r.Header.Get("Range") == "" {
+		if object.ChecksumCRC32C != "" {
+			w.Header().Set("X-Amz-Checksum-Crc32c", object.ChecksumCRC32C)
+		}
+		if object.ChecksumSHA256 != "" {
+			w.Header().Set("X-Amz-Checksum-Sha256", object.ChecksumSHA256)
+		}
+	}
+	//response header overrides of GetObject
+	for param, name := range responseOverrides {
+		if value := r.URL.Query().Get(param); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+
+	data := object.Data
+	status := http.StatusOK
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		start, end, ok := parseRange(rangeHeader, int64(len(data)))
+		if !ok {
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable")
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data = data[start : end+1]
+		status = http.StatusPartialContent
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method != "HEAD" {
+		_, _ = w.Write(data)
+	}
+}
+
+func parseRange(rangeHeader string, size int64) (int64, int64, bool) {
+	spec := strings.TrimPrefix(rangeHeader, "bytes=")
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 || size == 0 {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if parts[1] != "" {
+		if end, err = strconv.ParseInt(parts[1], 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Key       string   `xml:",omitempty"`
+	VersionId string   `xml:",omitempty"`
+	Code      string
+	Message   string
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeXml(w, status, errorResponse{Code: code, Message: message})
+}
+
+func writeXml(w http.ResponseWriter, status int, body interface{}) {
+	data, err := xml.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+}
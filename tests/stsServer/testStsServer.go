@@ -0,0 +1,198 @@
+// Package provides fake AWS STS server for tests of S3 credential providers
+package stsServer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Temporary credentials issued for role
+type Credentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Request received by test server
+type Request struct {
+	// AssumeRole or AssumeRoleWithWebIdentity
+	Action           string
+	RoleArn          string
+	RoleSessionName  string
+	ExternalId       string
+	WebIdentityToken string
+	// access key id of request signature, empty for unsigned requests
+	AccessKeyId string
+}
+
+// Server answers AssumeRole and AssumeRoleWithWebIdentity with credentials of registered roles
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	roles    map[string]Credentials
+	tokens   map[string]bool
+	requests []Request
+}
+
+// NewTestStsServer starts test server on random local port
+func NewTestStsServer() *Server {
+	server := &Server{roles: make(map[string]Credentials), tokens: make(map[string]bool)}
+	server.Server = httptest.NewServer(server)
+	return server
+}
+
+// AddRole registers role, which can be assumed with AssumeRole or AssumeRoleWithWebIdentity
+func (server *Server) AddRole(roleArn string, credentials Credentials) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.roles[roleArn] = credentials
+}
+
+// AddWebIdentityToken registers token accepted by AssumeRoleWithWebIdentity
+func (server *Server) AddWebIdentityToken(token string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.tokens[token] = true
+}
+
+// Requests returns all requests received by server
+func (server *Server) Requests() []Request {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return append([]Request{}, server.requests...)
+}
+
+type stsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+type assumeRoleResult struct {
+	Credentials     stsCredentials
+	AssumedRoleUser struct {
+		Arn           string
+		AssumedRoleId string
+	}
+}
+
+type responseMetadata struct {
+	RequestId string
+}
+
+type assumeRoleResponse struct {
+	XMLName          xml.Name         `xml:"AssumeRoleResponse"`
+	Result           assumeRoleResult `xml:"AssumeRoleResult"`
+	ResponseMetadata responseMetadata
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	XMLName          xml.Name         `xml:"AssumeRoleWithWebIdentityResponse"`
+	Result           assumeRoleResult `xml:"AssumeRoleWithWebIdentityResult"`
+	ResponseMetadata responseMetadata
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"ErrorResponse"`
+	Error   struct {
+		Type    string
+		Code    string
+		Message string
+	}
+	RequestId string
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidParameterValue", err.Error())
+		return
+	}
+	request := Request{
+		Action:           r.Form.Get("Action"),
+		RoleArn:          r.Form.Get("RoleArn"),
+		RoleSessionName:  r.Form.Get("RoleSessionName"),
+		ExternalId:       r.Form.Get("ExternalId"),
+		WebIdentityToken: r.Form.Get("WebIdentityToken"),
+		AccessKeyId:      signatureAccessKey(r.Header.Get("Authorization")),
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.requests = append(server.requests, request)
+
+	switch request.Action {
+	case "AssumeRole":
+		if request.AccessKeyId == "" {
+			writeError(w, http.StatusForbidden, "MissingAuthenticationToken", "request is not signed")
+			return
+		}
+	case "AssumeRoleWithWebIdentity":
+		if !server.tokens[request.WebIdentityToken] {
+			writeError(w, http.StatusBadRequest, "InvalidIdentityToken", "web identity token is not valid")
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "InvalidAction", "unsupported action: "+request.Action)
+		return
+	}
+	credentials, ok := server.roles[request.RoleArn]
+	if !ok {
+		writeError(w, http.StatusForbidden, "AccessDenied", "role can not be assumed: "+request.RoleArn)
+		return
+	}
+
+	result := assumeRoleResult{Credentials: stsCredentials{
+		AccessKeyId:     credentials.AccessKeyId,
+		SecretAccessKey: credentials.SecretAccessKey,
+		SessionToken:    credentials.SessionToken,
+		Expiration:      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}}
+	result.AssumedRoleUser.Arn = request.RoleArn + "/" + request.RoleSessionName
+	result.AssumedRoleUser.AssumedRoleId = credentials.AccessKeyId + ":" + request.RoleSessionName
+	metadata := responseMetadata{RequestId: fmt.Sprintf("request-%d", len(server.requests))}
+	if request.Action == "AssumeRole" {
+		writeXml(w, http.StatusOK, assumeRoleResponse{Result: result, ResponseMetadata: metadata})
+	} else {
+		writeXml(w, http.StatusOK, assumeRoleWithWebIdentityResponse{Result: result, ResponseMetadata: metadata})
+	}
+}
+
+// Returns access key id of AWS4-HMAC-SHA256 Authorization header
+func signatureAccessKey(authorization string) string {
+	i := strings.Index(authorization, "Credential=")
+	if i < 0 {
+		return ""
+	}
+	credential := authorization[i+len("Credential="):]
+	if j := strings.Index(credential, "/"); j >= 0 {
+		return credential[:j]
+	}
+	return ""
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	response := errorResponse{}
+	response.Error.Type = "Sender"
+	response.Error.Code = code
+	response.Error.Message = message
+	writeXml(w, status, response)
+}
+
+func writeXml(w http.ResponseWriter, status int, body interface{}) {
+	data, err := xml.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+}